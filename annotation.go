@@ -1,8 +1,11 @@
 package raml
 
 import (
+	"fmt"
 	"gopkg.in/yaml.v3"
 	"regexp"
+	"strconv"
+	"strings"
 )
 
 var annotationNameRegexp = regexp.MustCompile(`^\(.*\)$`)
@@ -10,41 +13,458 @@ var annotationNameRegexp = regexp.MustCompile(`^\(.*\)$`)
 //Annotations contains a map of referenced annotations and their values
 type Annotations struct {
 	AnnotationNames map[AnnotationName]interface{}
+
+	// the nodes of the values, by annotation
+	nodes map[AnnotationName]*yaml.Node
 }
 
 func (a *Annotations) UnmarshalYAML(node *yaml.Node) error {
 	var annotations = make(map[AnnotationName]interface{})
-	for i := 0; i < len(node.Content); i += 2 {
+	var nodes = make(map[AnnotationName]*yaml.Node)
+	for i := 0; i+1 < len(node.Content); i += 2 {
 		var keyNode = node.Content[i]
 		var valueNode = node.Content[i+1]
 
 		if annotationNameRegexp.MatchString(keyNode.Value) {
 			var values interface{}
-			switch valueNode.Kind {
-			case yaml.MappingNode:
-				err := valueNode.Decode(&values)
-				if err != nil {
-					return err
-				}
-				break
-			case yaml.ScalarNode:
-				values = valueNode.Value
-				break
+			if err := valueNode.Decode(&values); err != nil {
+				return err
 			}
 
 			annotations[AnnotationName(keyNode.Value)] = values
+			nodes[AnnotationName(keyNode.Value)] = valueNode
 		}
 	}
 
 	a.AnnotationNames = annotations
+	a.nodes = nodes
 
 	return nil
 }
 
+// Decode decodes the value of an applied annotation into v, as yaml.Unmarshal does, e.g.
+//
+//	var limit struct {
+//		Requests int    `yaml:"requests"`
+//		Period   string `yaml:"period"`
+//	}
+//	err := method.Annotations.Decode("(rateLimit)", &limit)
+//
+// The parentheses around the name of the annotation can be omitted.
+// It returns an error if the annotation isn't applied.
+func (a Annotations) Decode(name string, v interface{}) error {
+	if !annotationNameRegexp.MatchString(name) {
+		name = "(" + name + ")"
+	}
+	if node, ok := a.nodes[AnnotationName(name)]; ok {
+		return nodeFiles{}.decode(node, v)
+	}
+	value, ok := a.AnnotationNames[AnnotationName(name)]
+	if !ok {
+		return fmt.Errorf("annotation %s is not applied", name)
+	}
+	// an annotation which has not been decoded from a document
+	b, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(b, v)
+}
+
 //AnnotationName contains an annotation reference
 type AnnotationName string
 
-// AnnotationType describes the annotation
+// name returns the name of the annotation type the annotation refers to, e.g. `lib.badge` for `(lib.badge)`
+func (n AnnotationName) name() string {
+	return strings.TrimSuffix(strings.TrimPrefix(string(n), "("), ")")
+}
+
+// AnnotationTarget is a kind of node an annotation can be applied to
+type AnnotationTarget string
+
+// Targets of annotations
+const (
+	TargetAPI                    AnnotationTarget = "API"
+	TargetDocumentationItem      AnnotationTarget = "DocumentationItem"
+	TargetResource               AnnotationTarget = "Resource"
+	TargetMethod                 AnnotationTarget = "Method"
+	TargetResponse               AnnotationTarget = "Response"
+	TargetRequestBody            AnnotationTarget = "RequestBody"
+	TargetResponseBody           AnnotationTarget = "ResponseBody"
+	TargetTypeDeclaration        AnnotationTarget = "TypeDeclaration"
+	TargetExample                AnnotationTarget = "Example"
+	TargetResourceType           AnnotationTarget = "ResourceType"
+	TargetTrait                  AnnotationTarget = "Trait"
+	TargetSecurityScheme         AnnotationTarget = "SecurityScheme"
+	TargetSecuritySchemeSettings AnnotationTarget = "SecuritySchemeSettings"
+	TargetAnnotationType         AnnotationTarget = "AnnotationType"
+	TargetLibrary                AnnotationTarget = "Library"
+	TargetOverlay                AnnotationTarget = "Overlay"
+	TargetExtension              AnnotationTarget = "Extension"
+)
+
+var annotationTargets = []AnnotationTarget{TargetAPI, TargetDocumentationItem, TargetResource, TargetMethod,
+	TargetResponse, TargetRequestBody, TargetResponseBody, TargetTypeDeclaration, TargetExample,
+	TargetResourceType, TargetTrait, TargetSecurityScheme, TargetSecuritySchemeSettings, TargetAnnotationType,
+	TargetLibrary, TargetOverlay, TargetExtension}
+
+// AnnotationType describes the annotation: the type of its values,
+// declared as any other type, and the nodes it can be applied to.
+// An annotation type declaring no type takes strings, and as in any type
+// expression the `string?` shorthand stands for `string | nil`:
+//
+// annotationTypes:
+//   deprecated: nil
+//   badge: string?
+//   clearanceLevel:
+//     allowedTargets: [ Resource, Method ]
+//     properties:
+//       level:
+//         enum: [ low, medium, high ]
 type AnnotationType struct {
-	//TODO: fill this in
+	// The type of the values of the annotation
+	Type `yaml:",inline"`
+
+	// The kinds of nodes the annotation can be applied to, any if empty
+	AllowedTargets []AnnotationTarget `yaml:"allowedTargets"`
+}
+
+// UnmarshalYAML decodes an annotation type, which can be declared by its type expression only
+func (a *AnnotationType) UnmarshalYAML(node *yaml.Node) error {
+	*a = AnnotationType{}
+	if err := node.Decode(&a.Type); err != nil {
+		return err
+	}
+
+	targets := mappingValue(node, "allowedTargets")
+	if targets == nil {
+		return nil
+	}
+	delete(a.declaration, "allowedTargets")
+	if targets.Kind == yaml.ScalarNode {
+		a.AllowedTargets = []AnnotationTarget{AnnotationTarget(targets.Value)}
+		return nil
+	}
+	return targets.Decode(&a.AllowedTargets)
+}
+
+// article returns the target preceded by its article
+func (t AnnotationTarget) article() string {
+	if strings.ContainsAny(string(t[:1]), "AEIOU") {
+		return "an " + string(t)
+	}
+	return "a " + string(t)
+}
+
+// allows checks whether the annotation can be applied to a kind of node
+func (a AnnotationType) allows(target AnnotationTarget) bool {
+	return len(a.AllowedTargets) == 0 || containsTarget(a.AllowedTargets, target)
+}
+
+// annotationChecker validates the annotations applied throughout an API
+type annotationChecker struct {
+	p     *parser
+	api   *APIDefinition
+	types map[string]AnnotationType // all annotation types, by qualified name
+}
+
+// checkAnnotations validates the annotation types of the API and the annotations applied to its nodes
+func (d *APIDefinition) checkAnnotations(p *parser) error {
+	c := annotationChecker{p: p, api: d, types: d.allAnnotationTypes()}
+	libraries := d.allLibraries(map[string]*Library{}, d.Libraries)
+
+	for _, name := range sortedKeys(c.types) {
+		a := c.types[name]
+		path := annotationTypePath(name)
+		for _, target := range a.AllowedTargets {
+			if !containsTarget(annotationTargets, target) {
+				if err := p.errorf(CodeInvalidAnnotation, a.Position, append(path, "allowedTargets"),
+					"unknown annotation target %s", target); err != nil {
+					return err
+				}
+			}
+		}
+		if err := a.checkTypeExpressions(p, path); err != nil {
+			return err
+		}
+		if err := c.check(a.Annotations, TargetAnnotationType, name, path); err != nil {
+			return err
+		}
+	}
+
+	if err := c.check(d.Annotations, TargetAPI, "", nil); err != nil {
+		return err
+	}
+	if err := c.checkParameters(d.BaseURIParameters, []string{"baseUriParameters"}); err != nil {
+		return err
+	}
+	for i, doc := range d.Documentation {
+		path := []string{"documentation", strconv.Itoa(i)}
+		if err := c.check(doc.Annotations, TargetDocumentationItem, "", path); err != nil {
+			return err
+		}
+	}
+	for _, libName := range sortedKeys(libraries) {
+		// the annotations of a library refer to the annotation types of the library
+		scope := libName + "."
+		if err := c.check(libraries[libName].Annotations, TargetLibrary, scope, []string{"uses", libName}); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedKeys(d.Types) {
+		if err := c.checkType(d.Types[name], name); err != nil {
+			return err
+		}
+	}
+	for _, libName := range sortedKeys(libraries) {
+		lib := libraries[libName]
+		for _, name := range sortedKeys(lib.Types) {
+			if err := c.checkType(lib.Types[name], libName+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range sortedKeys(d.SecuritySchemes) {
+		s := d.SecuritySchemes[name]
+		path := []string{"securitySchemes", name}
+		if err := c.check(s.Annotations, TargetSecurityScheme, "", path); err != nil {
+			return err
+		}
+		if err := c.check(s.SettingsAnnotations, TargetSecuritySchemeSettings, "", append(path, "settings")); err != nil {
+			return err
+		}
+		if err := c.check(s.DescribedBy.Annotations, TargetSecurityScheme, "", append(path, "describedBy")); err != nil {
+			return err
+		}
+	}
+	if err := c.checkDeclarations(d.ResourceTypes, d.Traits, ""); err != nil {
+		return err
+	}
+	for _, libName := range sortedKeys(libraries) {
+		lib := libraries[libName]
+		if err := c.checkDeclarations(lib.ResourceTypes, lib.Traits, libName+"."); err != nil {
+			return err
+		}
+	}
+	for _, uri := range d.ResourceOrder {
+		r := d.Resources[uri]
+		if err := c.checkResource(&r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkType validates the annotations of the type qualified as name,
+// of its properties, which are type declarations, and of its examples
+func (c annotationChecker) checkType(t Type, name string) error {
+	path := typePath(name)
+	if err := c.check(t.Annotations, TargetTypeDeclaration, name, path); err != nil {
+		return err
+	}
+	for _, prop := range sortedKeys(t.propertyAnnotations) {
+		propPath := append(path[:len(path):len(path)], "properties", prop)
+		if err := c.check(t.propertyAnnotations[prop], TargetTypeDeclaration, name, propPath); err != nil {
+			return err
+		}
+	}
+	return c.checkExamples(t.NamedExamples, name, path)
+}
+
+// checkExamples validates the annotations of named examples
+func (c annotationChecker) checkExamples(examples map[string]Example, scope string, path []string) error {
+	for _, name := range sortedKeys(examples) {
+		examplePath := append(path[:len(path):len(path)], "examples", name)
+		if err := c.check(examples[name].Annotations, TargetExample, scope, examplePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDeclarations validates the annotations applied to resource types and traits,
+// declared in the library their names are qualified with
+func (c annotationChecker) checkDeclarations(resourceTypes map[string]ResourceType, traits map[string]Trait,
+	qualifier string) error {
+	for _, name := range sortedKeys(resourceTypes) {
+		qualified := qualifier + name
+		path := declarationPath("resourceTypes", qualified)
+		if err := c.check(resourceTypes[name].Annotations, TargetResourceType, qualified, path); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedKeys(traits) {
+		qualified := qualifier + name
+		if err := c.check(traits[name].Annotations, TargetTrait, qualified, declarationPath("traits", qualified)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkResource validates the annotations of a resource, of its methods and of its nested resources
+func (c annotationChecker) checkResource(r *Resource) error {
+	if err := c.check(r.Annotations, TargetResource, "", r.path()); err != nil {
+		return err
+	}
+	if err := c.checkParameters(r.URIParameters, r.path("uriParameters")); err != nil {
+		return err
+	}
+	if err := c.checkParameters(r.BaseURIParameters, r.path("baseUriParameters")); err != nil {
+		return err
+	}
+	for _, m := range r.Methods {
+		path := r.path(strings.ToLower(m.Name))
+		if err := c.check(m.Annotations, TargetMethod, "", path); err != nil {
+			return err
+		}
+		if err := c.checkParameters(m.QueryParameters, append(path[:len(path):len(path)], "queryParameters")); err != nil {
+			return err
+		}
+		if err := c.checkHeaders(m.Headers, append(path[:len(path):len(path)], "headers")); err != nil {
+			return err
+		}
+		if err := c.checkBodies(m.Bodies, TargetRequestBody, append(path[:len(path):len(path)], "body")); err != nil {
+			return err
+		}
+		for _, code := range m.ResponseOrder {
+			resp := m.Responses[code]
+			respPath := append(path[:len(path):len(path)], "responses", string(code))
+			if err := c.check(resp.Annotations, TargetResponse, "", respPath); err != nil {
+				return err
+			}
+			if err := c.checkHeaders(resp.Headers, append(respPath[:len(respPath):len(respPath)], "headers")); err != nil {
+				return err
+			}
+			if err := c.checkBodies(resp.Bodies, TargetResponseBody, append(respPath, "body")); err != nil {
+				return err
+			}
+		}
+	}
+	for _, uri := range r.NestedOrder {
+		if err := c.checkResource(r.Nested[uri]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkParameters validates the annotations of named parameters, which are type declarations
+func (c annotationChecker) checkParameters(params map[string]NamedParameter, path []string) error {
+	for _, name := range sortedKeys(params) {
+		paramPath := append(path[:len(path):len(path)], name)
+		if err := c.check(params[name].Annotations, TargetTypeDeclaration, "", paramPath); err != nil {
+			return err
+		}
+		if err := c.checkExamples(params[name].NamedExamples, "", paramPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkHeaders validates the annotations of headers
+func (c annotationChecker) checkHeaders(headers map[HTTPHeader]Header, path []string) error {
+	params := make(map[string]NamedParameter, len(headers))
+	for name, h := range headers {
+		params[string(name)] = NamedParameter(h)
+	}
+	return c.checkParameters(params, path)
+}
+
+// checkBodies validates the annotations of the body of each media type
+func (c annotationChecker) checkBodies(bodies Bodies, target AnnotationTarget, path []string) error {
+	if err := c.check(bodies.Annotations, target, "", path); err != nil {
+		return err
+	}
+	if err := c.checkExamples(bodies.NamedExamples, "", path); err != nil {
+		return err
+	}
+	for _, mediaType := range sortedKeys(bodies.ForMIMEType) {
+		bodyPath := append(path[:len(path):len(path)], mediaType)
+		if err := c.check(bodies.ForMIMEType[mediaType].Annotations, target, "", bodyPath); err != nil {
+			return err
+		}
+		if err := c.checkExamples(bodies.ForMIMEType[mediaType].NamedExamples, "", bodyPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check validates the annotations applied to a node: their annotation type has to be declared,
+// allowed to be applied to the node, and their value has to be an instance of its type.
+// Names are resolved from the declaration qualified as scope, the ones of a library referring
+// to the annotation types of the library.
+func (c annotationChecker) check(annotations Annotations, target AnnotationTarget, scope string, path []string) error {
+	for _, name := range sortedKeys(annotations.AnnotationNames) {
+		annotation := AnnotationName(name)
+		pos := c.p.files.position(annotations.nodes[annotation])
+		annotationPath := append(path[:len(path):len(path)], name)
+
+		qualified := scopedName(scope, annotation.name(), func(qualified string) bool {
+			_, ok := c.types[qualified]
+			return ok
+		})
+		a, ok := c.types[qualified]
+		if !ok {
+			if err := c.p.errorf(CodeUnknownAnnotation, pos, annotationPath, "unknown annotation %s", name); err != nil {
+				return err
+			}
+			continue
+		}
+		if !a.allows(target) {
+			if err := c.p.errorf(CodeInvalidAnnotation, pos, annotationPath, "annotation %s can't be applied to %s, only to %s",
+				name, target.article(), joinTargets(a.AllowedTargets)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		t, err := c.api.resolveType(a.facetMap(), qualified)
+		if err != nil {
+			// reported when checking the annotation types
+			continue
+		}
+		// applied without value, e.g. `(deprecated):`, the value is nil
+		v := instanceValidator{types: c.api.ResolvedTypes}
+		v.validate(t, annotations.AnnotationNames[annotation], "")
+		for _, violation := range v.violations {
+			if err := c.p.errorf(CodeInvalidAnnotation, pos, annotationPath, "invalid value of annotation %s, %s",
+				name, violation); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// annotationTypePath returns the path of the declaration of the annotation type qualified as name
+func annotationTypePath(name string) []string {
+	return declarationPath("annotationTypes", name)
+}
+
+// declarationPath returns the path of the declaration qualified as name,
+// declared under the given key, e.g. traits
+func declarationPath(key, name string) []string {
+	path := typePath(name)
+	path[len(path)-2] = key
+	return path
+}
+
+func containsTarget(targets []AnnotationTarget, target AnnotationTarget) bool {
+	for _, t := range targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+func joinTargets(targets []AnnotationTarget) string {
+	s := make([]string, len(targets))
+	for i, t := range targets {
+		s[i] = string(t)
+	}
+	return strings.Join(s, ", ")
 }
@@ -0,0 +1,119 @@
+package raml
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotationTypes(t *testing.T) {
+	asserter := assert.New(t)
+	file := filepath.Join("testdata", "annotations", "api.raml")
+
+	apiDefinition := new(APIDefinition)
+	diagnostics, err := ParseFileAll("./testdata/annotations/api.raml", apiDefinition)
+	asserter.Error(err)
+
+	// declarations
+	asserter.Equal([]AnnotationTarget{TargetMethod}, apiDefinition.AnnotationTypes["rateLimit"].AllowedTargets)
+	asserter.Len(apiDefinition.AnnotationTypes["rateLimit"].Properties, 2)
+	asserter.Equal("string[]", apiDefinition.AnnotationTypes["tags"].Type.Type)
+	asserter.Equal([]AnnotationTarget{TargetMethod},
+		apiDefinition.Libraries["policies"].AnnotationTypes["clearance"].AllowedTargets)
+
+	// sequences are kept
+	asserter.Equal([]interface{}{"internal", "beta"}, apiDefinition.Annotations.AnnotationNames["(tags)"])
+
+	type problem struct {
+		Code string
+		Path []string
+		Line int
+	}
+	var problems []problem
+	for _, d := range diagnostics {
+		asserter.Equal(file, d.Position.File, d.Message)
+		problems = append(problems, problem{d.Code, d.Path, d.Position.Line})
+	}
+	asserter.Equal([]problem{
+		{CodeInvalidAnnotation, []string{"annotationTypes", "audience", "allowedTargets"}, 16},
+		{CodeInvalidAnnotation, []string{"types", "User", "(owner)"}, 34},
+		{CodeInvalidAnnotation, []string{"types", "User", "(tags)"}, 39},
+		{CodeInvalidAnnotation, []string{"types", "User", "properties", "name", "(owner)"}, 38},
+		{CodeInvalidAnnotation, []string{"resourceTypes", "collection", "(owner)"}, 43},
+		{CodeInvalidAnnotation, []string{"traits", "paged", "(rateLimit)"}, 48},
+		{CodeInvalidAnnotation, []string{"/users", "get", "responses", "200", "(rateLimit)"}, 67},
+		{CodeInvalidAnnotation,
+			[]string{"/users", "get", "responses", "200", "body", "application/json", "examples", "first", "(owner)"}, 76},
+		{CodeInvalidAnnotation, []string{"/users", "post", "(policies.clearance)"}, 81},
+		{CodeInvalidAnnotation, []string{"/users", "post", "(rateLimit)"}, 79},
+		{CodeInvalidAnnotation, []string{"/users", "post", "(rateLimit)"}, 79},
+		{CodeInvalidAnnotation, []string{"/users", "post", "(tags)"}, 82},
+		{CodeUnknownAnnotation, []string{"/users", "post", "(unknown)"}, 83},
+	}, problems)
+	asserter.Contains(diagnostics[8].Message, "invalid value of annotation (policies.clearance), expected one of")
+	asserter.Contains(diagnostics[1].Message, "can't be applied to a TypeDeclaration, only to API, Resource")
+	asserter.Contains(diagnostics[2].Message, "expected array, got nil")
+	asserter.Contains(diagnostics[3].Message, "can't be applied to a TypeDeclaration, only to API, Resource")
+	asserter.Contains(diagnostics[4].Message, "can't be applied to a ResourceType, only to API, Resource")
+	asserter.Contains(diagnostics[5].Message, "can't be applied to a Trait, only to Method")
+	asserter.Contains(diagnostics[7].Message, "can't be applied to an Example, only to API, Resource")
+	asserter.Contains(diagnostics[9].Message, "/period: expected one of [second minute], got hour")
+}
+
+func TestAnnotatedNodes(t *testing.T) {
+	asserter := assert.New(t)
+
+	apiDefinition := new(APIDefinition)
+	_, err := ParseFileAll("./testdata/annotations/api.raml", apiDefinition)
+	asserter.Error(err)
+
+	tags := func(annotations Annotations) []string {
+		var values []string
+		asserter.NoError(annotations.Decode("tags", &values))
+		return values
+	}
+	asserter.Equal([]string{"docs"}, tags(apiDefinition.Documentation[0].Annotations))
+	asserter.Equal([]string{"auth"}, tags(apiDefinition.SecuritySchemes["token"].Annotations))
+	asserter.Equal([]string{"settings"}, tags(apiDefinition.SecuritySchemes["token"].SettingsAnnotations))
+	asserter.Equal("security", apiDefinition.Libraries["policies"].Annotations.AnnotationNames["(maintainer)"])
+
+	// annotations restricted to resource types and traits are not inherited
+	asserter.Equal("collections", apiDefinition.ResourceTypes["collection"].Annotations.AnnotationNames["(template)"])
+	asserter.Equal("paging", apiDefinition.Traits["paged"].Annotations.AnnotationNames["(template)"])
+	asserter.NotContains(apiDefinition.Resources["/users"].Annotations.AnnotationNames, AnnotationName("(template)"))
+
+	get := apiDefinition.Resources["/users"].Get
+	asserter.NotContains(get.Annotations.AnnotationNames, AnnotationName("(template)"))
+	asserter.Contains(get.Annotations.AnnotationNames, AnnotationName("(deprecated)"))
+	asserter.Equal([]string{"paging"}, tags(get.QueryParameters["page"].Annotations))
+	response := get.Responses["200"]
+	asserter.Contains(response.Annotations.AnnotationNames, AnnotationName("(rateLimit)"))
+	asserter.Equal([]string{"payload"}, tags(response.Bodies.ForMIMEType["application/json"].Annotations))
+
+	var limit struct {
+		Requests int    `yaml:"requests"`
+		Period   string `yaml:"period"`
+	}
+	asserter.NoError(get.Annotations.Decode("(rateLimit)", &limit))
+	asserter.Equal(100, limit.Requests)
+	asserter.Equal("minute", limit.Period)
+	asserter.Error(get.Annotations.Decode("(owner)", &limit))
+
+	// annotations of properties and named examples
+	badge := apiDefinition.Libraries["policies"].Types["Badge"]
+	asserter.Equal("levels", badge.GetProperty("level").Annotations.AnnotationNames["(maintainer)"])
+	var maintainer string
+	asserter.NoError(badge.NamedExamples["high"].Annotations.Decode("maintainer", &maintainer))
+	asserter.Equal("examples", maintainer)
+	asserter.Equal(map[string]interface{}{"level": "high"}, badge.NamedExamples["high"].Value)
+	asserter.Equal(map[string]interface{}{"level": "low"}, badge.NamedExamples["low"].Value)
+	asserter.Empty(badge.NamedExamples["low"].Annotations.AnnotationNames)
+	example := response.Bodies.ForMIMEType["application/json"].NamedExamples["first"]
+	asserter.Equal([]string{"sample"}, tags(example.Annotations))
+	asserter.Equal(filepath.Join("testdata", "annotations", "api.raml"), example.Position.File)
+
+	// annotations not decoded from a document
+	annotations := Annotations{AnnotationNames: map[AnnotationName]interface{}{"(tags)": []interface{}{"a", "b"}}}
+	asserter.Equal([]string{"a", "b"}, tags(annotations))
+}
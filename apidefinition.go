@@ -16,6 +16,7 @@ type APIDefinition struct {
 	definitionProps `yaml:",inline"`
 	RAMLVersion     string      `yaml:"-"`
 	Annotations     Annotations `yaml:",inline"`
+	Position        Position    `yaml:",inline"`
 }
 
 type definitionProps struct {
@@ -73,6 +74,10 @@ type definitionProps struct {
 	// Declarations of (data) types for use within the API.
 	Types map[string]Type `yaml:"types"`
 
+	// The types of the API and of the libraries it uses, resolved against the
+	// types they inherit from, by qualified name, e.g. `lib.Person`.
+	ResolvedTypes map[string]*ResolvedType `yaml:"-"`
+
 	// Declarations of traits for use within the API.
 	Traits map[string]Trait `yaml:"traits"`
 
@@ -80,7 +85,7 @@ type definitionProps struct {
 	ResourceTypes map[string]ResourceType `yaml:"resourceTypes"`
 
 	// Declarations of annotation types for use by Annotations.
-	AnnotationTypes map[string]interface{} `yaml:"annotationTypes"`
+	AnnotationTypes map[string]AnnotationType `yaml:"annotationTypes"`
 
 	// Declarations of security schemes for use within the API.
 	SecuritySchemes map[string]SecurityScheme `yaml:"securitySchemes"`
@@ -91,11 +96,23 @@ type definitionProps struct {
 	// Imported external libraries for use within the API.
 	Uses map[string]string `yaml:"uses"`
 
+	// The location of the API definition an overlay or extension applies to.
+	// The definition of an overlay or extension is the result of merging it
+	// into the one it extends.
+	Extends string `yaml:"extends"`
+
 	// The resources of the API, identified as relative URIs that begin with a slash (/).
 	// A resource property is one that begins with the slash and is either
 	// at the root of the API definition or a child of a resource property. For example, /users and /{groupId}.
 	Resources map[string]Resource `yaml:"-"`
 
+	// The URIs of the resources, and the names of the types, traits and
+	// resource types declared by the API, in declaration order.
+	ResourceOrder     []string `yaml:"-"`
+	TypeOrder         []string `yaml:"-"`
+	TraitOrder        []string `yaml:"-"`
+	ResourceTypeOrder []string `yaml:"-"`
+
 	Libraries map[string]*Library `yaml:"-"`
 
 	Filename string `yaml:"-"`
@@ -105,12 +122,16 @@ type definitionProps struct {
 func (d *APIDefinition) UnmarshalYAML(node *yaml.Node) error {
 	type clone APIDefinition
 
+	var errs typeErrors
 	c := clone{}
-	if err := node.Decode(&c); err != nil {
+	if err := errs.add(node.Decode(&c)); err != nil {
 		return err
 	}
 
-	if err := node.Decode(&c.Annotations); err != nil {
+	if err := errs.add(node.Decode(&c.Annotations)); err != nil {
+		return err
+	}
+	if err := errs.add(decodeSecuredBy(node, &c.SecuredBy)); err != nil {
 		return err
 	}
 
@@ -125,23 +146,28 @@ func (d *APIDefinition) UnmarshalYAML(node *yaml.Node) error {
 				continue
 			}
 
-			err := valueNode.Decode(&resource)
-			if err != nil {
+			if err := errs.add(valueNode.Decode(&resource)); err != nil {
 				return err
 			}
 			c.definitionProps.Resources[keyNode.Value] = resource
+			c.definitionProps.ResourceOrder = append(c.definitionProps.ResourceOrder, keyNode.Value)
 		}
 	}
+	c.TypeOrder = mappingKeys(mappingValue(node, "types"), nil)
+	c.TraitOrder = mappingKeys(mappingValue(node, "traits"), nil)
+	c.ResourceTypeOrder = mappingKeys(mappingValue(node, "resourceTypes"), nil)
 
+	c.RAMLVersion = d.RAMLVersion
 	*d = APIDefinition(c)
 
-	return nil
+	return errs.err()
 }
 
 // Documentation is the additional overall documentation for the API.
 type Documentation struct {
-	Title   string `yaml:"title"`
-	Content string `yaml:"content"`
+	Title       string      `yaml:"title"`
+	Content     string      `yaml:"content"`
+	Annotations Annotations `yaml:",inline"`
 }
 
 //MediaType contains the default media types to use for request and response bodies (payloads)
@@ -165,22 +191,57 @@ func (m *MediaType) UnmarshalYAML(node *yaml.Node) (err error) {
 	return err
 }
 
+// receiveHeader only accepts API definitions, overlays and extensions
+func (d *APIDefinition) receiveHeader(version string, kind FragmentKind) error {
+	if kind != FragmentAPI && !isExtensionKind(kind) {
+		return fmt.Errorf("expected an API definition, found %s", kind.article())
+	}
+	d.RAMLVersion = version
+	return nil
+}
+
 // PostProcess doing additional processing
 // that couldn't be done by yaml parser such as :
 // - inheritance
 // - setting some additional values not exist in the .raml
 // - allocate map fields
 func (d *APIDefinition) PostProcess(workDir, fileName string) error {
+	p := newParser()
+	if err := d.postProcess(p, workDir, fileName); err != nil {
+		return err
+	}
+	return p.err()
+}
+
+func (d *APIDefinition) postProcess(p *parser, workDir, fileName string) error {
 	d.Filename = strings.Join([]string{workDir, fileName}, "")
 	d.Libraries = map[string]*Library{}
 
-	for name, useFileName := range d.Uses {
-		lib := &Library{Filename: strings.Join([]string{workDir, useFileName}, "")}
+	if len(d.Schemas) > 0 {
+		if err := p.warnf(CodeDeprecated, d.Position, []string{"schemas"},
+			"schemas is deprecated, use types instead"); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range sortedKeys(d.Uses) {
+		lib, err := p.parseLibrary(fileDir(workDir, fileName), d.Uses[name], []string{"uses", name})
+		if err != nil {
+			return err
+		}
+		if lib != nil {
+			d.Libraries[name] = lib
+		}
+	}
 
-		if _, err := ParseReadFile(workDir, useFileName, lib); err != nil {
-			return fmt.Errorf("d.PostProcess() failed to parse library	name=%v, path=%v\n\terr=%v", name, useFileName, err)
+	// security schemes
+	for _, name := range sortedKeys(d.SecuritySchemes) {
+		s := d.SecuritySchemes[name]
+		s.Name = name
+		if err := s.check(p, []string{"securitySchemes", name}); err != nil {
+			return err
 		}
-		d.Libraries[name] = lib
+		d.SecuritySchemes[name] = s
 	}
 
 	// traits
@@ -189,9 +250,12 @@ func (d *APIDefinition) PostProcess(workDir, fileName string) error {
 		d.Traits[name] = t
 	}
 
+	traits := allTraits(d.Traits, d.Libraries)
+
 	// resource types
-	for name, rt := range d.ResourceTypes {
-		err := rt.postProcess(name, d.Traits, d)
+	for _, name := range sortedKeys(d.ResourceTypes) {
+		rt := d.ResourceTypes[name]
+		err := rt.postProcess(p, name, traits, d.MediaType)
 		if err != nil {
 			return err
 		}
@@ -199,25 +263,55 @@ func (d *APIDefinition) PostProcess(workDir, fileName string) error {
 	}
 
 	// types
-	for name, t := range d.Types {
-		err := t.postProcess(name, d)
-		if err != nil {
+	for _, name := range sortedKeys(d.Types) {
+		t := d.Types[name]
+		if err := t.checkTypeExpressions(p, []string{"types", name}); err != nil {
 			return err
 		}
+		if t.Schema != nil {
+			if err := p.warnf(CodeDeprecated, t.Position, []string{"types", name, "schema"},
+				"schema is deprecated, use type instead"); err != nil {
+				return err
+			}
+		}
+		if err := t.postProcess(name, d); err != nil {
+			if err = p.errorf(CodeInvalidType, t.Position, []string{"types", name}, "%s", err.Error()); err != nil {
+				return err
+			}
+		}
 		d.Types[name] = t
 	}
+	if err := d.resolveTypes(p); err != nil {
+		return err
+	}
+
+	decls := declarations{
+		resourceTypes:   d.allResourceTypes(d.ResourceTypes, d.Libraries),
+		traits:          traits,
+		libraries:       d.allLibraries(map[string]*Library{}, d.Libraries),
+		mediaTypes:      d.MediaType,
+		annotationTypes: d.allAnnotationTypes(),
+		files:           p.files,
+	}
+	if err := checkResourceTypes(p, decls.resourceTypes); err != nil {
+		return err
+	}
 
 	// resources
-	for k := range d.Resources {
+	for _, k := range sortedKeys(d.Resources) {
 		r := d.Resources[k]
-		rts := d.allResourceTypes(d.ResourceTypes, d.Libraries)
-		traits := d.allTraits(d.Traits, d.Libraries)
-		if err := r.postProcess(k, nil, rts, traits, d); err != nil {
+		if err := r.postProcess(p, k, nil, decls); err != nil {
 			return err
 		}
 		d.Resources[k] = r
 	}
-	return nil
+	if err := d.checkExamples(p); err != nil {
+		return err
+	}
+	if err := d.checkAnnotations(p); err != nil {
+		return err
+	}
+	return d.resolveSecurity(p)
 }
 
 // FindLibFile find library dir and file by it's name we also search from included library
@@ -280,11 +374,39 @@ func (d *APIDefinition) allResourceTypes(rts map[string]ResourceType, libraries
 	return rts
 }
 
-// allTraits gets all traits that defined in this api definition.
+// allLibraries gets all libraries used by this api definition,
+// directly or through other libraries
+func (d *APIDefinition) allLibraries(all map[string]*Library, libraries map[string]*Library) map[string]*Library {
+	for libName, l := range libraries {
+		all[libName] = l
+		// Recursively processing siblings
+		if l.Libraries != nil {
+			d.allLibraries(all, l.Libraries)
+		}
+	}
+	return all
+}
+
+// allAnnotationTypes gets all annotation types of this api definition and of
+// the libraries it uses, by qualified name
+func (d *APIDefinition) allAnnotationTypes() map[string]AnnotationType {
+	types := map[string]AnnotationType{}
+	for name, a := range d.AnnotationTypes {
+		types[name] = a
+	}
+	for libName, lib := range d.allLibraries(map[string]*Library{}, d.Libraries) {
+		for name, a := range lib.AnnotationTypes {
+			types[libName+"."+name] = a
+		}
+	}
+	return types
+}
+
+// allTraits gets all traits that defined in an api definition or library.
 // traits could be from:
-// - the root APIDefinition
+// - the root APIDefinition or Library
 // - libraries
-func (d *APIDefinition) allTraits(traits map[string]Trait, libraries map[string]*Library) map[string]Trait {
+func allTraits(traits map[string]Trait, libraries map[string]*Library) map[string]Trait {
 	if len(traits) == 0 {
 		traits = map[string]Trait{}
 	}
@@ -294,7 +416,7 @@ func (d *APIDefinition) allTraits(traits map[string]Trait, libraries map[string]
 		}
 		// Recursively processing siblings
 		if l.Libraries != nil {
-			d.allTraits(traits, l.Libraries)
+			allTraits(traits, l.Libraries)
 		}
 	}
 	return traits
@@ -302,22 +424,17 @@ func (d *APIDefinition) allTraits(traits map[string]Trait, libraries map[string]
 
 // create new type
 func (d *APIDefinition) createType(name string, tip interface{},
-	inputProps map[interface{}]interface{}) bool {
+	inputProps map[string]interface{}) bool {
 
 	// check that there is no type with this name
 	if _, exist := d.Types[name]; exist {
 		return false
 	}
 
-	// convert the inputProps to properties
-	props := make(map[string]interface{})
-
+	// copy the inputProps to properties
+	props := make(map[string]interface{}, len(inputProps))
 	for k, p := range inputProps {
-		name, ok := k.(string)
-		if !ok {
-			panic(fmt.Errorf("property key:%v need to be a string", k))
-		}
-		props[name] = p
+		props[k] = p
 	}
 
 	t := Type{
@@ -328,5 +445,6 @@ func (d *APIDefinition) createType(name string, tip interface{},
 		},
 	}
 	d.Types[name] = t
+	d.TypeOrder = append(d.TypeOrder, name)
 	return true
 }
@@ -88,6 +88,9 @@ type DefinitionParameters map[string]interface{}
 type DefinitionChoice struct {
 	Name string
 
+	// Where the choice has been made.
+	Position Position
+
 	// The definitions of resource types and traits MAY contain parameters,
 	// whose values MUST be specified when applying the resource type or trait,
 	// UNLESS the parameter corresponds to a reserved parameter name, in which
@@ -98,6 +101,7 @@ type DefinitionChoice struct {
 
 // UnmarshalYAML unmarshals a node which MIGHT be a simple string or a map[string]DefinitionParameters
 func (dc *DefinitionChoice) UnmarshalYAML(node *yaml.Node) (err error) {
+	dc.Position = positionOf(node)
 	switch node.Kind {
 	case yaml.ScalarNode:
 		simpleDefinition := new(string)
@@ -125,6 +129,32 @@ func (dc *DefinitionChoice) UnmarshalYAML(node *yaml.Node) (err error) {
 	return err
 }
 
+// decodeSecuredBy decodes the security schemes a node is secured by, keeping the `null` ones,
+// which allow anonymous access and are decoded as choices without name
+func decodeSecuredBy(node *yaml.Node, choices *[]DefinitionChoice) error {
+	value := mappingValue(node, "securedBy")
+	switch {
+	case value == nil:
+		return nil
+	case isNullNode(value):
+		*choices = []DefinitionChoice{{Position: positionOf(value)}}
+		return nil
+	case value.Kind != yaml.SequenceNode:
+		return nil
+	}
+	*choices = make([]DefinitionChoice, 0, len(value.Content))
+	for _, item := range value.Content {
+		dc := DefinitionChoice{Position: positionOf(item)}
+		if !isNullNode(item) {
+			if err := item.Decode(&dc); err != nil {
+				return err
+			}
+		}
+		*choices = append(*choices, dc)
+	}
+	return nil
+}
+
 // HasProperties is interface of all objects that
 // contains RAML properties
 type HasProperties interface {
@@ -135,7 +165,43 @@ type HasProperties interface {
 type Type struct {
 	typeProps   `yaml:",inline"`
 	Annotations Annotations    `yaml:",inline"`
+	Position    Position       `yaml:",inline"`
 	_apiDef     *APIDefinition `yaml:"-"`
+
+	// the facets declared by the type, as given by its declaration
+	declaration map[string]interface{}
+
+	// the annotations applied to the properties of the type, by name
+	propertyAnnotations map[string]Annotations
+}
+
+// UnmarshalYAML decodes a type, which can be declared by its type expression only,
+// e.g. `Person[]`, or by the types it inherits from
+func (t *Type) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode || node.Kind == yaml.SequenceNode {
+		*t = Type{}
+		if err := node.Decode(&t.Position); err != nil {
+			return err
+		}
+		err := node.Decode(&t.Type)
+		t.declaration = map[string]interface{}{"type": t.Type}
+		return err
+	}
+	type clone Type
+	c := clone{}
+	err := node.Decode(&c)
+	*t = Type(c)
+	var declErr error
+	if declErr = node.Decode(&t.declaration); declErr != nil && err == nil {
+		err = declErr
+	}
+	if t.propertyAnnotations, declErr = decodePropertyAnnotations(node); declErr != nil && err == nil {
+		err = declErr
+	}
+	if t.NamedExamples, declErr = decodeNamedExamples(node); declErr != nil && err == nil {
+		err = declErr
+	}
+	return err
 }
 
 type typeProps struct {
@@ -181,6 +247,9 @@ type typeProps struct {
 	// when the "example" property is already defined.
 	Examples map[string]interface{} `yaml:"examples" json:"examples"`
 
+	// The examples of Examples, decoded along with their annotations.
+	NamedExamples map[string]Example `yaml:"-" json:"-"`
+
 	// An alternate, human-friendly name for the type
 	DisplayName string `yaml:"displayName" json:"displayName"`
 
@@ -282,6 +351,9 @@ func (t *Type) GetProperty(name string) Property {
 
 	prop := toProperty(name, propInterface)
 	prop._type = t
+	if annotations, ok := t.propertyAnnotations[name]; ok {
+		prop.Annotations = annotations
+	}
 
 	return prop
 }
@@ -386,13 +458,21 @@ func (t typeProps) TypeString() string {
 	return interfaceToString(t.Type)
 }
 
+// TypeExpression returns the syntax tree of the type expression of this type.
+// It returns an error if the type isn't declared by a type expression,
+// e.g. for an inlined schema.
+func (t typeProps) TypeExpression() (TypeExpression, error) {
+	return parseTypeFacet(t.Type)
+}
+
 // IsArray checks if this type is an Array
 // see specs at http://docs.raml.org/specs/1.0/#raml-10-spec-array-types
 func (t typeProps) IsArray() bool {
-	if t.IsJSONType() {
-		return false
+	if t.TypeString() == arrayType {
+		return true
 	}
-	return t.TypeString() == arrayType || strings.HasSuffix(t.TypeString(), "[]")
+	_, ok := arrayItemsOf(t.Type)
+	return ok
 }
 
 // ArrayType returns type of the array
@@ -400,22 +480,32 @@ func (t typeProps) ArrayType() string {
 	if t.TypeString() == "array" {
 		return interfaceToString(t.Items)
 	}
-	return strings.TrimSuffix(t.TypeString(), "[]")
+	if items, ok := arrayItemsOf(t.Type); ok {
+		return items.String()
+	}
+	return t.TypeString()
 }
 
 // IsBidimensionalArray returns true
 // if it is a bidimensional array
 func (t typeProps) IsBidimensionalArray() bool {
-	if t.IsJSONType() {
+	items, ok := arrayItemsOf(t.Type)
+	if !ok {
 		return false
 	}
-	return strings.HasSuffix(t.TypeString(), "[][]")
+	_, ok = items.(*ArrayType)
+	return ok
 }
 
 // BidimensiArrayType returns type
 // of a bidimensional array
 func (t typeProps) BidimensiArrayType() string {
-	return strings.TrimSuffix(t.TypeString(), "[][]")
+	if items, ok := arrayItemsOf(t.Type); ok {
+		if array, ok := items.(*ArrayType); ok {
+			return ungroup(array.Items).String()
+		}
+	}
+	return t.TypeString()
 }
 
 // IsEnum type check if this type is an enum
@@ -427,20 +517,19 @@ func (t typeProps) IsEnum() bool {
 // IsUnion checks if a type is Union type
 // see http://docs.raml.org/specs/1.0/#raml-10-spec-union-types
 func (t typeProps) IsUnion() bool {
-	if t.IsJSONType() {
-		return false
-	}
-	return strings.Index(t.TypeString(), "|") > 0
+	_, ok := unionOf(t.Type)
+	return ok
 }
 
 // Union returns union type of this type
 func (t typeProps) Union() ([]string, bool) {
-	if !t.IsUnion() {
+	types, ok := unionOf(t.Type)
+	if !ok {
 		return nil, false
 	}
 	var tips []string
-	for _, ut := range strings.Split(t.TypeString(), "|") {
-		tips = append(tips, strings.TrimSpace(ut))
+	for _, ut := range types {
+		tips = append(tips, ungroup(ut).String())
 	}
 	return tips, true
 }
@@ -464,7 +553,7 @@ func (t *Type) postProcess(name string, apiDef *APIDefinition) error {
 	}
 
 	// process type in properties
-	for name := range t.Properties {
+	for _, name := range sortedKeys(t.Properties) {
 		t.parseOptionalProperty(name)
 		err := t.createTypeFromPropProperty(name, apiDef)
 		if err != nil {
@@ -495,16 +584,19 @@ func (t *typeProps) parseOptionalProperty(name string) {
 	case string:
 		// if it is a simple string
 		// convert it to map style property
-		newProp := map[interface{}]interface{}{}
+		newProp := map[string]interface{}{}
 		newProp["type"] = p
 		newProp["required"] = false
 		t.Properties[newName] = newProp
 
-	case map[interface{}]interface{}:
+	case map[string]interface{}:
 		// already in map style property
-		propMap := p.(map[interface{}]interface{})
+		propMap := p.(map[string]interface{})
 		propMap["required"] = false
 		t.Properties[newName] = propMap
+	case nil:
+		// a property without declaration is a string
+		t.Properties[newName] = map[string]interface{}{"required": false}
 	default:
 		log.Fatalf("unexpeced property type: %v", p)
 	}
@@ -515,7 +607,7 @@ func (t *typeProps) createTypeFromPropItems(name string, apiDef *APIDefinition)
 	p := t.Properties[name]
 
 	// propMap is this properties as map
-	propMap, ok := p.(map[interface{}]interface{})
+	propMap, ok := p.(map[string]interface{})
 	if !ok {
 		return nil
 	}
@@ -533,13 +625,13 @@ func (t *typeProps) createTypeFromPropItems(name string, apiDef *APIDefinition)
 	}
 
 	// check it's validity
-	items, ok := itemsIf.(map[interface{}]interface{})
+	items, ok := itemsIf.(map[string]interface{})
 	if !ok {
 		return nil
 	}
 
 	// to define new type, it needs to have 'properties' field
-	props, ok := items["properties"].(map[interface{}]interface{})
+	props, ok := items["properties"].(map[string]interface{})
 	if !ok { // doesn't define new type, no problem, we can simply return
 		return nil
 	}
@@ -566,7 +658,7 @@ func (t *typeProps) createTypeFromPropItems(name string, apiDef *APIDefinition)
 func (t *typeProps) createTypeFromPropProperty(name string, apiDef *APIDefinition) error {
 	p := t.Properties[name]
 	// only process map[interface]interface{}
-	propMap, ok := p.(map[interface{}]interface{})
+	propMap, ok := p.(map[string]interface{})
 	if !ok {
 		return nil
 	}
@@ -578,7 +670,7 @@ func (t *typeProps) createTypeFromPropProperty(name string, apiDef *APIDefinitio
 	}
 
 	// check validity of the properties
-	props, ok := propsIf.(map[interface{}]interface{})
+	props, ok := propsIf.(map[string]interface{})
 	if !ok {
 		return fmt.Errorf("inline properties expect properties in type:map[string]interface{}")
 	}
@@ -607,8 +699,7 @@ func (t *typeProps) postProcessJSONSchema() error {
 	var jt JSONSchema
 
 	if err := json.Unmarshal([]byte(t.TypeString()), &jt); err != nil {
-		fmt.Println("failed to marshal json")
-		return err
+		return fmt.Errorf("invalid JSON schema: %v", err)
 	}
 	jt.PostUnmarshal()
 
@@ -623,3 +714,52 @@ func (t *typeProps) postProcessJSONSchema() error {
 	t.Type = "object"
 	return nil
 }
+
+// checkTypeExpressions reports the type expressions of this type
+// and of its properties that are not valid.
+func (t *Type) checkTypeExpressions(p *parser, path []string) error {
+	check := func(tip interface{}, path []string) error {
+		expr, ok := tip.(string)
+		if !ok || expr == "" || isSchemaType(expr) {
+			return nil
+		}
+		if _, err := ParseTypeExpression(expr); err != nil {
+			return p.errorf(CodeInvalidTypeExpression, t.Position, path, "invalid type expression %q: %v", expr, err)
+		}
+		return nil
+	}
+
+	if err := check(t.Type, appendPath(path, "type")); err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(t.Properties) {
+		propPath := appendPath(path, "properties", name)
+		switch v := t.Properties[name].(type) {
+		case string:
+			if err := check(v, propPath); err != nil {
+				return err
+			}
+		case map[string]interface{}:
+			if err := check(v["type"], appendPath(propPath, "type")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isSchemaType returns true if a type is an inlined JSON or XML schema
+func isSchemaType(tip string) bool {
+	tip = strings.TrimSpace(tip)
+	return strings.HasPrefix(tip, "{") || strings.HasPrefix(tip, "<")
+}
+
+// parseTypeFacet parses the value of a type facet, which can also be
+// an inline declaration or schema rather than a type expression
+func parseTypeFacet(tip interface{}) (TypeExpression, error) {
+	expr, ok := tip.(string)
+	if !ok || isSchemaType(expr) {
+		return nil, fmt.Errorf("%v is not a type expression", interfaceToString(tip))
+	}
+	return ParseTypeExpression(expr)
+}
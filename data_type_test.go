@@ -8,7 +8,7 @@ import (
 
 func TestTypeInType(t *testing.T) {
 	apiDef := new(APIDefinition)
-	SkipConvey("Type in type's properties", t, func() {
+	Convey("Type in type's properties", t, func() {
 		err := ParseFile("./testdata/types.raml", apiDef)
 		So(err, ShouldBeNil)
 
@@ -43,6 +43,11 @@ func TestTypeInType(t *testing.T) {
 		_, ok = apiDef.Types["Actionrecurringcombo"]
 		So(ok, ShouldBeTrue)
 
+		// the type of a property declared with a format is kept
+		interval := ar.GetProperty("interval")
+		So(interval.Type, ShouldEqual, "integer")
+		So(*interval.Format, ShouldEqual, "int32")
+
 		combo := ar.GetProperty("combo")
 		So(combo.TypeString(), ShouldEqual, "Actionrecurringcombo")
 
@@ -31,92 +31,182 @@ package raml
 
 import (
 	"fmt"
-	"gopkg.in/yaml.v3"
+	"regexp"
+	"strconv"
 	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity tells how serious a Diagnostic is.
+type Severity int
+
+const (
+	// SeverityError marks a problem making the document invalid.
+	SeverityError Severity = iota
+	// SeverityWarning marks a suspicious construct that does not invalidate the document.
+	SeverityWarning
 )
 
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// Codes identifying the kind of problem a Diagnostic reports.
+const (
+	CodeInvalidFile           = "invalid-file"
+	CodeInvalidHeader         = "invalid-header"
+	CodeFragmentMismatch      = "fragment-mismatch"
+	CodeInvalidExtends        = "invalid-extends"
+	CodeInvalidOverlay        = "invalid-overlay"
+	CodeYAML                  = "yaml"
+	CodeInvalidInclude        = "invalid-include"
+	CodeInvalidLibrary        = "invalid-library"
+	CodeCircularInclude       = "circular-include"
+	CodeCircularLibrary       = "circular-library"
+	CodeUnknownTrait          = "unknown-trait"
+	CodeUnknownResourceType   = "unknown-resource-type"
+	CodeCircularResourceType  = "circular-resource-type"
+	CodeInvalidType           = "invalid-type"
+	CodeInvalidTypeExpression = "invalid-type-expression"
+	CodeCircularType          = "circular-type"
+	CodeInvalidInheritance    = "invalid-inheritance"
+	CodeInvalidExample        = "invalid-example"
+	CodeUnknownAnnotation     = "unknown-annotation"
+	CodeInvalidAnnotation     = "invalid-annotation"
+	CodeUnknownSecurityScheme = "unknown-security-scheme"
+	CodeInvalidSecurityScheme = "invalid-security-scheme"
+	CodePostProcess           = "post-process"
+	CodeDeprecated            = "deprecated"
+	CodeMigration             = "migration"
+)
+
+// Diagnostic describes a single problem found while parsing a RAML document.
+type Diagnostic struct {
+	Severity Severity
+
+	// Code identifies the kind of problem, e.g. CodeUnknownTrait.
+	Code string
+
+	// Human-friendly description of the problem.
+	Message string
+
+	// Where the problem has been found.
+	Position Position
+
+	// Path of the element holding the problem, as the keys leading to it
+	// from the root of the document, e.g. ["/users", "get", "is"].
+	Path []string
+}
+
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s: %s", d.Position, d.Severity, d.Message)
+	if len(d.Path) > 0 {
+		fmt.Fprintf(&b, " (at %s)", strings.Join(d.Path, "."))
+	}
+	if d.Code != "" {
+		fmt.Fprintf(&b, " [%s]", d.Code)
+	}
+	return b.String()
+}
+
 // An Error is returned by the ParseFile function when RAML or YAML problems
 // are encountered when parsing the RAML document.
 type Error struct {
-	Errors []string
+	Diagnostics []Diagnostic
 }
 
 func (e *Error) Error() string {
+	errors := make([]string, 0, len(e.Diagnostics))
+	for _, d := range e.Diagnostics {
+		errors = append(errors, d.String())
+	}
 	return fmt.Sprintf("Error parsing RAML:\n  %s\n",
-		strings.Join(e.Errors, "\n  "))
+		strings.Join(errors, "\n  "))
 }
 
-// Populate the RAML error value with converted YAML error strings (with
-// additional context)
-func populateRAMLError(ramlError *Error, yamlErrors *yaml.TypeError) {
+// typeErrors accumulates the *yaml.TypeError returned while decoding several nodes,
+// so that a mismatch somewhere doesn't prevent the rest of a document from being decoded.
+type typeErrors []string
 
-	// Go over the errors
-	for _, currErr := range yamlErrors.Errors {
-
-		// Create the RAML errors
-		ramlError.Errors =
-			append(ramlError.Errors, convertYAMLError(currErr))
+// add records err if it is a *yaml.TypeError, any other error is returned as is
+func (e *typeErrors) add(err error) error {
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		*e = append(*e, typeErr.Errors...)
+		return nil
 	}
+	return err
 }
 
-// Convert a YAML error string into RAML error string, with more context
-func convertYAMLError(yamlError string) string {
-
-	if strings.Contains(yamlError, "cannot unmarshal") {
-
-		yamlErrorParts := strings.Split(yamlError, " ")
-
-		if len(yamlErrorParts) >= 7 {
-
-			fmt.Println(yamlError)
-
-			var ok bool
-			var source string
-			var target string
-			var targetName string
-			line := yamlErrorParts[1]
-			line = line[:len(line)-1]
+// err returns a *yaml.TypeError holding all the recorded errors, if any
+func (e typeErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return &yaml.TypeError{Errors: e}
+}
 
-			// TODO: support more complex types:
-			// map[string]raml.NamedParameter -->
-			// detect map, format to:
-			//   "mapping of %s to %s", ramlTypeNames["string"], ramlTypeNames["raml.NamedParameter"]
-			// if "string" is not found, use the key, i.e. "string" in this case.
-			// so the output would be:
-			//   mapping of string to named parameter
+var (
+	yamlLineRegexp      = regexp.MustCompile(`line (\d+): `)
+	yamlUnmarshalRegexp = regexp.MustCompile("^line (\\d+): cannot unmarshal (\\S+)(?: `(.*)`)? into (.+)$")
+)
 
-			// TODO: instead of having string in the key of some mappings,
-			// perhaps use a type alias:
-			//   type Name string
-			//   map[Name]NamedParameter
-			// would output:
-			//   mapping of name string to named parameter
+// Convert a YAML error string into a RAML diagnostic, with more context.
+// file is the file being decoded when the error happened.
+func convertYAMLError(file, yamlError string) Diagnostic {
+	d := Diagnostic{
+		Severity: SeverityError,
+		Code:     CodeYAML,
+		Position: Position{File: file},
+	}
 
-			if source, ok = yamlTypeToName[yamlErrorParts[4]]; !ok {
-				source = yamlErrorParts[4]
-			}
-			fmt.Println("source: ", source)
+	if m := yamlUnmarshalRegexp.FindStringSubmatch(yamlError); m != nil {
+		d.Position.Line, _ = strconv.Atoi(m[1])
 
-			if source == "string" {
-				source = fmt.Sprintf("string (got %s)", yamlErrorParts[5])
-				target = yamlErrorParts[7]
-			} else {
-				target = yamlErrorParts[6]
+		// TODO: support more complex types:
+		// map[string]raml.NamedParameter -->
+		// detect map, format to:
+		//   "mapping of %s to %s", ramlTypeNames["string"], ramlTypeNames["raml.NamedParameter"]
+		// if "string" is not found, use the key, i.e. "string" in this case.
+		// so the output would be:
+		//   mapping of string to named parameter
 
-			}
-			if targetName, ok = ramlTypeNames[target]; !ok {
-				targetName = target
-			}
+		source, ok := yamlTypeToName[m[2]]
+		if !ok {
+			source = m[2]
+		}
+		if source == "string" && m[3] != "" {
+			source = fmt.Sprintf("string (got %s)", m[3])
+		}
 
-			target, _ = ramlTypes[target]
+		target := m[4]
+		targetName, ok := ramlTypeNames[target]
+		if !ok {
+			targetName = target
+		}
+		if targetType, ok := ramlTypes[target]; ok {
+			target = targetType
+		}
 
-			return fmt.Sprintf("line %s: %s cannot be of type %s, must be %s", line, targetName, source, target)
+		d.Message = fmt.Sprintf("%s cannot be of type %s, must be %s", targetName, source, target)
+		return d
+	}
 
-		}
+	if m := yamlLineRegexp.FindStringSubmatch(yamlError); m != nil {
+		d.Position.Line, _ = strconv.Atoi(m[1])
 	}
 
 	// Otherwise
-	return fmt.Sprintf("YAML error, %s", yamlError)
+	d.Message = fmt.Sprintf("YAML error, %s", yamlError)
+	return d
 }
 
 var yamlTypeToName = map[string]string{
@@ -0,0 +1,86 @@
+package raml
+
+import (
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDiagnostics(t *testing.T) {
+	Convey("diagnostics", t, func() {
+		file := filepath.Join("testdata", "errors.raml")
+
+		Convey("collecting every problem", func() {
+			diagnostics, err := ParseFileAll("./testdata/errors.raml", new(APIDefinition))
+			So(err, ShouldNotBeNil)
+			So(err.(*Error).Diagnostics, ShouldHaveLength, 5)
+
+			codes := map[string][]Diagnostic{}
+			for _, d := range diagnostics {
+				So(d.Severity, ShouldEqual, SeverityError)
+				codes[d.Code] = append(codes[d.Code], d)
+			}
+
+			So(codes[CodeInvalidInclude], ShouldHaveLength, 1)
+			So(codes[CodeInvalidInclude][0].Position, ShouldResemble, Position{File: file, Line: 24, Column: 22})
+			So(codes[CodeInvalidInclude][0].Path, ShouldResemble,
+				[]string{"/users", "get", "responses", "200", "body", "application/json", "example"})
+
+			So(codes[CodeInvalidTypeExpression], ShouldHaveLength, 2)
+
+			So(codes[CodeUnknownTrait], ShouldHaveLength, 1)
+			So(codes[CodeUnknownTrait][0].Message, ShouldContainSubstring, "secured")
+			So(codes[CodeUnknownTrait][0].Position, ShouldResemble, Position{File: file, Line: 19, Column: 18})
+			So(codes[CodeUnknownTrait][0].Path, ShouldResemble, []string{"/users", "get", "is"})
+
+			So(codes[CodeUnknownResourceType], ShouldHaveLength, 1)
+			So(codes[CodeUnknownResourceType][0].Position, ShouldResemble, Position{File: file, Line: 17, Column: 9})
+		})
+
+		Convey("in the same order every time", func() {
+			first, _ := ParseFileAll("./testdata/errors.raml", new(APIDefinition))
+			for i := 0; i < 5; i++ {
+				diagnostics, _ := ParseFileAll("./testdata/errors.raml", new(APIDefinition))
+				So(diagnostics, ShouldResemble, first)
+			}
+		})
+
+		Convey("of libraries in the order of their names", func() {
+			for i := 0; i < 10; i++ {
+				diagnostics, err := ParseFileAll("./testdata/libraries/broken/api.raml", new(APIDefinition))
+				So(err, ShouldNotBeNil)
+				var files []string
+				for _, d := range diagnostics {
+					files = append(files, filepath.Base(d.Position.File))
+				}
+				So(files, ShouldResemble, []string{"alpha.raml", "bravo.raml", "charlie.raml", "delta.raml"})
+			}
+		})
+
+		Convey("stopping at the first problem", func() {
+			err := ParseFile("./testdata/errors.raml", new(APIDefinition))
+			So(err, ShouldNotBeNil)
+			So(err.(*Error).Diagnostics, ShouldHaveLength, 1)
+			So(err.(*Error).Diagnostics[0].Code, ShouldEqual, CodeInvalidInclude)
+		})
+
+		Convey("of YAML errors in included files", func() {
+			diagnostics, err := ParseFileAll("./testdata/includes/search.raml", new(APIDefinition))
+			So(err, ShouldNotBeNil)
+			So(diagnostics, ShouldHaveLength, 1)
+			So(diagnostics[0].Code, ShouldEqual, CodeYAML)
+			So(diagnostics[0].Position, ShouldResemble,
+				Position{File: filepath.Join("testdata", "includes", "query.raml"), Line: 4})
+		})
+
+		Convey("converting YAML errors", func() {
+			d := convertYAMLError("api.raml", "line 3: cannot unmarshal !!str `abc` into int")
+			So(d.Position, ShouldResemble, Position{File: "api.raml", Line: 3})
+			So(d.Code, ShouldEqual, CodeYAML)
+			So(d.Message, ShouldEqual, "numeric value cannot be of type string (got abc), must be integer")
+			So(d.String(), ShouldEqual,
+				"api.raml:3:0: error: numeric value cannot be of type string (got abc), must be integer [yaml]")
+		})
+	})
+}
@@ -0,0 +1,340 @@
+package raml
+
+import (
+	"encoding/json"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// This file implements the validation of the examples of an API against
+// the types they illustrate: the examples of types, bodies and named
+// parameters.
+//
+// An example is either the instance itself, or a map holding the instance
+// under the `value` key, along with its displayName, description, strict
+// flag and annotations. Examples declared with `strict: false` are not
+// validated.
+
+// keys of an example declared as a map holding its value
+var exampleKeys = []string{"value", "displayName", "description", "strict"}
+
+// Example is one of the named examples of a type, body or named parameter
+type Example struct {
+	// The instance the example is made of
+	Value interface{}
+
+	// Whether the example is validated against its type, true unless declared otherwise
+	Strict bool
+
+	DisplayName string
+	Description string
+
+	Annotations Annotations
+
+	// Where the example has been declared.
+	Position Position
+}
+
+// UnmarshalYAML decodes an example, either the instance itself or a map holding it
+func (e *Example) UnmarshalYAML(node *yaml.Node) error {
+	*e = Example{Strict: true, Position: positionOf(node)}
+	var example interface{}
+	if err := node.Decode(&example); err != nil {
+		return err
+	}
+	m, ok := exampleDeclaration(example)
+	if !ok {
+		e.Value = example
+		return nil
+	}
+	e.Value, e.Strict = exampleValue(example)
+	e.DisplayName, _ = m["displayName"].(string)
+	e.Description, _ = m["description"].(string)
+	return node.Decode(&e.Annotations)
+}
+
+// decodeNamedExamples decodes the examples facet of the declaration of a type, body or named parameter
+func decodeNamedExamples(node *yaml.Node) (map[string]Example, error) {
+	examples := mappingValue(node, "examples")
+	if examples == nil || examples.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	named := make(map[string]Example, len(examples.Content)/2)
+	for i := 0; i+1 < len(examples.Content); i += 2 {
+		var e Example
+		if err := examples.Content[i+1].Decode(&e); err != nil {
+			return nil, err
+		}
+		named[examples.Content[i].Value] = e
+	}
+	return named, nil
+}
+
+// exampleDeclaration returns the map an example is declared with,
+// if it's declared as a map holding its value
+func exampleDeclaration(example interface{}) (map[string]interface{}, bool) {
+	m, ok := example.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	if _, ok := m["value"]; !ok {
+		return nil, false
+	}
+	for key := range m {
+		if !containsString(exampleKeys, key) && !strings.HasPrefix(key, "(") {
+			// an object which happens to have a value property
+			return nil, false
+		}
+	}
+	return m, true
+}
+
+// exampleValue returns the instance an example is made of, and whether it
+// has to be validated against its type
+func exampleValue(example interface{}) (interface{}, bool) {
+	m, ok := exampleDeclaration(example)
+	if !ok {
+		return example, true
+	}
+	strict, ok := m["strict"].(bool)
+	return m["value"], !ok || strict
+}
+
+// checkExamples validates the examples of the types, bodies and named parameters of the API
+func (d *APIDefinition) checkExamples(p *parser) error {
+	for _, name := range sortedKeys(d.Types) {
+		t := d.Types[name]
+		if err := d.checkTypeExamples(p, name, t); err != nil {
+			return err
+		}
+	}
+	libraries := d.allLibraries(map[string]*Library{}, d.Libraries)
+	for _, libName := range sortedKeys(libraries) {
+		lib := libraries[libName]
+		for _, name := range sortedKeys(lib.Types) {
+			if err := d.checkTypeExamples(p, libName+"."+name, lib.Types[name]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := d.checkParameterExamples(p, d.BaseURIParameters, []string{"baseUriParameters"}); err != nil {
+		return err
+	}
+	for _, uri := range d.ResourceOrder {
+		r := d.Resources[uri]
+		if err := d.checkResourceExamples(p, &r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkTypeExamples validates the examples of the type qualified as name
+func (d *APIDefinition) checkTypeExamples(p *parser, name string, t Type) error {
+	if t.Example == nil && len(t.Examples) == 0 {
+		return nil
+	}
+	resolved, ok := d.ResolvedTypes[name]
+	if !ok {
+		return nil
+	}
+	return d.checkExampleSet(p, resolved, "", t.Example, t.Examples, t.Position, typePath(name))
+}
+
+// checkResourceExamples validates the examples of the parameters and bodies of a resource
+// and of its nested resources
+func (d *APIDefinition) checkResourceExamples(p *parser, r *Resource) error {
+	if err := d.checkParameterExamples(p, r.URIParameters, r.path("uriParameters")); err != nil {
+		return err
+	}
+	if err := d.checkParameterExamples(p, r.BaseURIParameters, r.path("baseUriParameters")); err != nil {
+		return err
+	}
+	for _, m := range r.Methods {
+		path := r.path(strings.ToLower(m.Name))
+		if err := d.checkParameterExamples(p, m.QueryParameters, append(path, "queryParameters")); err != nil {
+			return err
+		}
+		if err := d.checkHeaderExamples(p, m.Headers, append(path, "headers")); err != nil {
+			return err
+		}
+		if err := d.checkBodyExamples(p, m.Bodies, append(path, "body")); err != nil {
+			return err
+		}
+		for _, code := range m.ResponseOrder {
+			resp := m.Responses[code]
+			respPath := append(path, "responses", string(code))
+			if err := d.checkHeaderExamples(p, resp.Headers, append(respPath, "headers")); err != nil {
+				return err
+			}
+			if err := d.checkBodyExamples(p, resp.Bodies, append(respPath, "body")); err != nil {
+				return err
+			}
+		}
+	}
+	for _, uri := range r.NestedOrder {
+		if err := d.checkResourceExamples(p, r.Nested[uri]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkParameterExamples validates the examples of named parameters
+func (d *APIDefinition) checkParameterExamples(p *parser, params map[string]NamedParameter, path []string) error {
+	for _, name := range sortedKeys(params) {
+		np := params[name]
+		if np.Example == nil && len(np.Examples) == 0 {
+			continue
+		}
+		paramPath := append(path[:len(path):len(path)], name)
+		t, err := d.resolveType(np.facetMap(), "")
+		if err != nil {
+			if err = p.errorf(CodeInvalidExample, np.Position, paramPath, "the example can't be validated: %v", err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = d.checkExampleSet(p, t, "", np.Example, np.Examples, np.Position, paramPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkHeaderExamples validates the examples of headers
+func (d *APIDefinition) checkHeaderExamples(p *parser, headers map[HTTPHeader]Header, path []string) error {
+	params := make(map[string]NamedParameter, len(headers))
+	for name, h := range headers {
+		params[string(name)] = NamedParameter(h)
+	}
+	return d.checkParameterExamples(p, params, path)
+}
+
+// checkBodyExamples validates the examples of the body of each media type
+func (d *APIDefinition) checkBodyExamples(p *parser, bodies Bodies, path []string) error {
+	check := func(b Body, path []string) error {
+		if b.Example == nil && len(b.Examples) == 0 {
+			return nil
+		}
+		t, err := d.resolveType(b.facetMap(), "")
+		if err != nil {
+			return p.errorf(CodeInvalidExample, b.Position, path, "the examples can't be validated: %v", err)
+		}
+		return d.checkExampleSet(p, t, b.MediaType, b.Example, b.Examples, b.Position, path)
+	}
+
+	if err := check(bodies.Body, path); err != nil {
+		return err
+	}
+	for _, mediaType := range sortedKeys(bodies.ForMIMEType) {
+		if err := check(bodies.ForMIMEType[mediaType], append(path[:len(path):len(path)], mediaType)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkExampleSet validates the example and the named examples of a type
+func (d *APIDefinition) checkExampleSet(p *parser, t *ResolvedType, mediaType string, example interface{},
+	examples map[string]interface{}, pos Position, path []string) error {
+	if example != nil {
+		if err := d.checkExample(p, t, mediaType, example, pos, append(path[:len(path):len(path)], "example")); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedKeys(examples) {
+		examplePath := append(path[:len(path):len(path)], "examples", name)
+		if err := d.checkExample(p, t, mediaType, examples[name], pos, examplePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkExample validates an example against its type, reporting each violation
+func (d *APIDefinition) checkExample(p *parser, t *ResolvedType, mediaType string, example interface{},
+	pos Position, path []string) error {
+	value, strict := exampleValue(example)
+	if !strict {
+		return nil
+	}
+	value, ok := exampleInstance(t, mediaType, value)
+	if !ok {
+		return nil
+	}
+	v := instanceValidator{types: d.ResolvedTypes}
+	v.validate(t, value, "")
+	for _, violation := range v.violations {
+		if err := p.errorf(CodeInvalidExample, pos, path, "invalid example, %s", violation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exampleInstance returns the instance an example value stands for:
+// examples of structured types can be given as JSON documents.
+// It returns false if the example can't be validated, such as an XML document.
+func exampleInstance(t *ResolvedType, mediaType string, value interface{}) (interface{}, bool) {
+	s, ok := value.(string)
+	if !ok || t.Kind == KindScalar || t.Kind == KindAny {
+		return value, true
+	}
+	var instance interface{}
+	if err := json.Unmarshal([]byte(s), &instance); err == nil {
+		return instance, true
+	}
+	return value, mediaType == "" || strings.Contains(mediaType, "json") || strings.Contains(mediaType, "yaml")
+}
+
+// facetMap returns the facets declared by the parameter
+func (np NamedParameter) facetMap() map[string]interface{} {
+	facets := map[string]interface{}{}
+	if np.Type != "" {
+		facets["type"] = np.Type
+	}
+	if enum, ok := np.Enum.([]interface{}); ok {
+		facets["enum"] = enum
+	}
+	if np.Format != "" {
+		facets["format"] = np.Format
+	}
+	if np.Pattern != nil {
+		facets["pattern"] = *np.Pattern
+	}
+	if np.MinLength != nil {
+		facets["minLength"] = *np.MinLength
+	}
+	if np.MaxLength != nil {
+		facets["maxLength"] = *np.MaxLength
+	}
+	if np.Minimum != nil {
+		facets["minimum"] = *np.Minimum
+	}
+	if np.Maximum != nil {
+		facets["maximum"] = *np.Maximum
+	}
+	return facets
+}
+
+// facetMap returns the facets declared by the body
+func (b Body) facetMap() map[string]interface{} {
+	facets := map[string]interface{}{}
+	if b.Type != nil {
+		facets["type"] = b.Type
+	}
+	if b.Properties != nil {
+		facets["properties"] = b.Properties
+	}
+	if b.Items != nil {
+		facets["items"] = b.Items
+	}
+	if b.Type == nil && b.Properties == nil && b.Items == nil {
+		// a body declaring no type can be anything
+		facets["type"] = "any"
+	}
+	return facets
+}
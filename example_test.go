@@ -0,0 +1,48 @@
+package raml
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExamples(t *testing.T) {
+	asserter := assert.New(t)
+	file := filepath.Join("testdata", "examples.raml")
+
+	diagnostics, err := ParseFileAll("./testdata/examples.raml", new(APIDefinition))
+	asserter.Error(err)
+
+	var paths [][]string
+	for _, d := range diagnostics {
+		asserter.Equal(CodeInvalidExample, d.Code)
+		asserter.Equal(file, d.Position.File)
+		paths = append(paths, d.Path)
+	}
+	asserter.Equal([][]string{
+		{"types", "Code", "examples", "dollar"},
+		{"types", "Currency", "example"},
+		{"/currencies/{code}", "uriParameters", "code", "example"},
+		{"/currencies/{code}", "get", "queryParameters", "limit", "example"},
+		{"/currencies/{code}", "get", "queryParameters", "order", "example"},
+		{"/currencies/{code}", "get", "queryParameters", "page", "examples", "invalid"},
+		{"/currencies/{code}", "get", "responses", "200", "body", "application/json", "examples", "invalid"},
+		{"/currencies/{code}", "get", "responses", "404", "body", "application/json"},
+	}, paths)
+
+	asserter.Contains(diagnostics[1].Message, "/name: missing required property name")
+	asserter.Equal(27, diagnostics[2].Position.Line)
+	asserter.Contains(diagnostics[4].Message, "[enum]")
+	asserter.Contains(diagnostics[5].Message, "expected integer")
+	asserter.Contains(diagnostics[7].Message, "Missing")
+
+	asserter.Equal([]interface{}{"EUR", true}, tuple(exampleValue("EUR")))
+	asserter.Equal([]interface{}{"tbd", false}, tuple(exampleValue(map[string]interface{}{"value": "tbd", "strict": false})))
+	asserter.Equal([]interface{}{map[string]interface{}{"value": 1, "unit": "kg"}, true},
+		tuple(exampleValue(map[string]interface{}{"value": 1, "unit": "kg"})))
+}
+
+func tuple(values ...interface{}) []interface{} {
+	return values
+}
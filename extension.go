@@ -0,0 +1,221 @@
+package raml
+
+import (
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// This file implements overlays and extensions, which are merged into the
+// API definition they extend on the nodes of the documents, before they are
+// decoded and before resource types and traits are applied:
+//   - properties missing from the extended document are added
+//   - objects present in both are merged
+//   - items of arrays missing from the extended document are added
+//   - scalars of the overlay or extension replace the ones of the extended document
+//
+// An overlay only adds or changes documentation: titles, descriptions,
+// display names, usages, examples, annotations and annotation types.
+
+// keys of an overlay or extension that are not merged into the extended document
+var extensionOwnKeys = []string{"extends", "usage"}
+
+// keys of the nodes an overlay may add or change
+var overlayKeys = []string{"title", "description", "displayName", "documentation", "usage", "example", "examples"}
+
+// isExtensionKind returns true for the kinds of documents extending an API definition
+func isExtensionKind(kind FragmentKind) bool {
+	return kind == FragmentOverlay || kind == FragmentExtension
+}
+
+// extend replaces an overlay or extension document by the result of merging
+// it into the document it extends, loaded with all of its includes.
+func (p *parser) extend(document *yaml.Node, kind FragmentKind, workDir, fileName string) error {
+	location := fileLocation(workDir, fileName)
+
+	var source *yaml.Node
+	if len(document.Content) > 0 {
+		source = document.Content[0]
+	}
+	i := mappingIndex(source, "extends")
+	if i < 0 || source.Content[i+1].Kind != yaml.ScalarNode || strings.TrimSpace(source.Content[i+1].Value) == "" {
+		return p.errorf(CodeInvalidExtends, Position{File: location, Line: 1, Column: 1}, []string{"extends"},
+			"%s has to extend an API definition", kind.article())
+	}
+	extends := source.Content[i+1]
+
+	dir := fileDir(workDir, fileName)
+	target, err := p.loadExtended(dir, strings.TrimSpace(extends.Value), p.files.position(extends))
+	if err != nil || target == nil {
+		return err
+	}
+
+	if kind == FragmentOverlay {
+		if err = p.checkOverlay(target, source, nil); err != nil {
+			return err
+		}
+	}
+
+	merged := *source
+	merged.Content = nil
+	for j := 0; j+1 < len(source.Content); j += 2 {
+		if !containsString(extensionOwnKeys, source.Content[j].Value) {
+			merged.Content = append(merged.Content, source.Content[j], source.Content[j+1])
+		}
+	}
+	mergeExtension(target, &merged)
+
+	// the result remembers what it extends
+	if j := mappingIndex(target, "extends"); j >= 0 {
+		target.Content[j], target.Content[j+1] = source.Content[i], extends
+	} else {
+		target.Content = append(target.Content, source.Content[i], extends)
+	}
+	document.Content[0] = target
+	return nil
+}
+
+// loadExtended loads the document extended by an overlay or extension,
+// extending in turn the one it extends if it's an overlay or extension itself.
+// It returns the root node of the document.
+func (p *parser) loadExtended(workDir, fileName string, pos Position) (*yaml.Node, error) {
+	path := []string{"extends"}
+	location := fileLocation(workDir, fileName)
+	if cycle := p.cycle(location); cycle != nil {
+		return nil, p.errorf(CodeInvalidExtends, pos, path, "circular extends: %s", strings.Join(cycle, " -> "))
+	}
+
+	contents, err := p.load(workDir, fileName)
+	if err != nil {
+		return nil, p.errorf(CodeInvalidExtends, pos, path, "error loading %s: %s", fileName, err.Error())
+	}
+	defer p.enter(location)()
+
+	version, kind, err := parseHeader(strings.SplitN(string(contents), "\n", 2)[0])
+	if err != nil {
+		return nil, p.errorf(CodeInvalidHeader, Position{File: location, Line: 1, Column: 1}, nil, "%s", err.Error())
+	}
+	if kind != FragmentAPI && !isExtensionKind(kind) {
+		return nil, p.errorf(CodeInvalidExtends, pos, path, "%s is %s, expected an API definition",
+			fileName, kind.article())
+	}
+
+	var document yaml.Node
+	if err = yaml.Unmarshal(contents, &document); err != nil {
+		return nil, p.report(convertYAMLError(location, err.Error()))
+	}
+	p.files.register(&document, location)
+	if err = p.preProcess(&document, fileDir(workDir, fileName), nil); err != nil {
+		return nil, err
+	}
+	if document.Kind == 0 {
+		return nil, p.errorf(CodeInvalidExtends, pos, path, "%s is empty", fileName)
+	}
+	if version == ramlVersion08 {
+		upgradeDocument(&document, p.files)
+	}
+
+	if isExtensionKind(kind) {
+		if err = p.extend(&document, kind, workDir, fileName); err != nil {
+			return nil, err
+		}
+	}
+	return document.Content[0], nil
+}
+
+// relocateUses makes the libraries used by the result of extending documents,
+// relative to the document that declared them, relative to dir instead:
+// the directory of the overlay or extension the result is read from.
+func relocateUses(document *yaml.Node, dir string, files nodeFiles) {
+	if len(document.Content) == 0 {
+		return
+	}
+	uses := mappingValue(document.Content[0], "uses")
+	if uses == nil || uses.Kind != yaml.MappingNode || isURL(dir) {
+		return
+	}
+	for i := 1; i < len(uses.Content); i += 2 {
+		file := files.position(uses.Content[i]).File
+		if file == "" {
+			continue
+		}
+		location := fileLocation(fileDir("", file), uses.Content[i].Value)
+		if isURL(location) {
+			uses.Content[i].Value = location
+		} else if rel, err := filepath.Rel(dir, location); err == nil {
+			uses.Content[i].Value = rel
+		}
+	}
+}
+
+// mergeExtension merges the nodes of an overlay or extension into the ones of the document it extends
+func mergeExtension(target, source *yaml.Node) {
+	switch {
+	case target.Kind == yaml.MappingNode && source.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(source.Content); i += 2 {
+			key, value := source.Content[i], source.Content[i+1]
+			j := mappingIndex(target, key.Value)
+			switch {
+			case j < 0:
+				target.Content = append(target.Content, key, value)
+			case target.Content[j+1].Kind == value.Kind && value.Kind != yaml.ScalarNode:
+				mergeExtension(target.Content[j+1], value)
+			default:
+				target.Content[j+1] = value
+			}
+		}
+	case target.Kind == yaml.SequenceNode && source.Kind == yaml.SequenceNode:
+		for _, item := range source.Content {
+			if !containsNode(target.Content, item) {
+				target.Content = append(target.Content, item)
+			}
+		}
+	}
+}
+
+// checkOverlay reports the nodes of an overlay that add or change the behavior
+// of the document it extends, rather than its documentation
+func (p *parser) checkOverlay(target, source *yaml.Node, path []string) error {
+	name := "the document"
+	if len(path) > 0 {
+		name = path[len(path)-1]
+	}
+	if target != nil && isNullNode(target) && source.Kind == yaml.MappingNode {
+		target = &yaml.Node{Kind: yaml.MappingNode}
+	}
+	switch {
+	case target != nil && target.Kind == yaml.MappingNode && source.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(source.Content); i += 2 {
+			key, value := source.Content[i], source.Content[i+1]
+			j := mappingIndex(target, key.Value)
+			switch {
+			case containsString(overlayKeys, key.Value) || annotationNameRegexp.MatchString(key.Value):
+			case len(path) == 0 && (containsString(extensionOwnKeys, key.Value) || key.Value == "uses"):
+			case len(path) == 1 && path[0] == "annotationTypes" && j < 0:
+			case j < 0:
+				if err := p.errorf(CodeInvalidOverlay, p.files.position(key), appendPath(path, key.Value),
+					"an overlay can't add %s", key.Value); err != nil {
+					return err
+				}
+			default:
+				if err := p.checkOverlay(target.Content[j+1], value, appendPath(path, key.Value)); err != nil {
+					return err
+				}
+			}
+		}
+	case target != nil && target.Kind == yaml.SequenceNode && source.Kind == yaml.SequenceNode:
+		for _, item := range source.Content {
+			if !containsNode(target.Content, item) {
+				return p.errorf(CodeInvalidOverlay, p.files.position(item), path,
+					"an overlay can't add items to %s", name)
+			}
+		}
+	default:
+		if target == nil || !equalNodes(target, source) {
+			return p.errorf(CodeInvalidOverlay, p.files.position(source), path,
+				"an overlay can't change %s", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package raml
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsingOverlays(t *testing.T) {
+	asserter := assert.New(t)
+
+	apiDefinition := new(APIDefinition)
+	err := ParseFile("./testdata/extensions/overlays/fr.raml", apiDefinition)
+	asserter.NoError(err)
+	asserter.Equal("../api.raml", apiDefinition.Extends)
+
+	// documentation is translated
+	asserter.Equal("API des livres", apiDefinition.Title)
+	asserter.Len(apiDefinition.Documentation, 2)
+	asserter.Equal("Bienvenue", apiDefinition.Documentation[1].Content)
+	asserter.Contains(apiDefinition.AnnotationTypes, "translated")
+	books := apiDefinition.Resources["/books"]
+	asserter.Equal("Tous les livres", books.Description)
+	asserter.Equal("Liste les livres", books.Get.Description)
+	asserter.Equal("fr", books.Annotations.AnnotationNames["(translated)"])
+
+	// behavior is kept, libraries being resolved relative to the extended document
+	asserter.Equal("https://api.example.com", apiDefinition.BaseURI)
+	asserter.Contains(books.Get.Responses, HTTPCode("200"))
+	asserter.Contains(apiDefinition.Libraries, "common")
+	asserter.Contains(apiDefinition.Libraries["common"].Types, "Book")
+
+	// merged nodes keep their position in the extended document
+	asserter.Equal(filepath.Join("testdata", "extensions", "api.raml"), books.Get.Position.File)
+}
+
+func TestParsingExtensions(t *testing.T) {
+	asserter := assert.New(t)
+
+	// an extension of an overlay
+	apiDefinition := new(APIDefinition)
+	err := ParseFile("./testdata/extensions/extensions/staging.raml", apiDefinition)
+	asserter.NoError(err)
+	asserter.Equal("API des livres", apiDefinition.Title)
+	asserter.Equal("https://staging.example.com", apiDefinition.BaseURI)
+	asserter.Equal([]string{"HTTPS", "HTTP"}, apiDefinition.Protocols)
+	asserter.Equal("Liste les livres", apiDefinition.Resources["/books"].Get.Description)
+	asserter.Equal("Adds a book", apiDefinition.Resources["/books"].Post.Description)
+	asserter.Contains(apiDefinition.Resources, "/admin")
+	asserter.Contains(apiDefinition.Libraries, "common")
+}
+
+func TestInvalidExtensions(t *testing.T) {
+	asserter := assert.New(t)
+
+	// overlays can't change behavior
+	diagnostics, err := ParseFileAll("./testdata/extensions/overlays/invalid.raml", new(APIDefinition))
+	asserter.Error(err)
+	asserter.Len(diagnostics, 2)
+	for _, d := range diagnostics {
+		asserter.Equal(CodeInvalidOverlay, d.Code)
+	}
+	asserter.Equal([]string{"baseUri"}, diagnostics[0].Path)
+	asserter.Equal([]string{"/books", "post"}, diagnostics[1].Path)
+
+	err = ParseFile("./testdata/extensions/overlays/circular.raml", new(APIDefinition))
+	asserter.Error(err)
+	asserter.Equal(CodeInvalidExtends, err.(*Error).Diagnostics[0].Code)
+	asserter.Contains(err.Error(), "circular extends")
+
+	err = ParseFile("./testdata/extensions/extensions/library.raml", new(APIDefinition))
+	asserter.Error(err)
+	asserter.Contains(err.Error(), "../libs/common.raml is a library, expected an API definition")
+}
@@ -0,0 +1,216 @@
+package raml
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FragmentKind is the kind of a RAML document, declared after the version
+// in its header line, e.g. `#%RAML 1.0 DataType`.
+type FragmentKind string
+
+// Kinds of RAML documents
+const (
+	// FragmentAPI is the kind of a document without fragment kind: an API definition
+	FragmentAPI                       FragmentKind = ""
+	FragmentLibrary                   FragmentKind = "Library"
+	FragmentDataType                  FragmentKind = "DataType"
+	FragmentTrait                     FragmentKind = "Trait"
+	FragmentResourceType              FragmentKind = "ResourceType"
+	FragmentSecurityScheme            FragmentKind = "SecurityScheme"
+	FragmentNamedExample              FragmentKind = "NamedExample"
+	FragmentDocumentationItem         FragmentKind = "DocumentationItem"
+	FragmentAnnotationTypeDeclaration FragmentKind = "AnnotationTypeDeclaration"
+	FragmentOverlay                   FragmentKind = "Overlay"
+	FragmentExtension                 FragmentKind = "Extension"
+)
+
+var fragmentKinds = []FragmentKind{FragmentAPI, FragmentLibrary, FragmentDataType, FragmentTrait,
+	FragmentResourceType, FragmentSecurityScheme, FragmentNamedExample, FragmentDocumentationItem,
+	FragmentAnnotationTypeDeclaration, FragmentOverlay, FragmentExtension}
+
+func (k FragmentKind) String() string {
+	switch k {
+	case FragmentAPI:
+		return "API definition"
+	case FragmentLibrary:
+		return "library"
+	case FragmentOverlay, FragmentExtension:
+		return strings.ToLower(string(k))
+	}
+	return string(k) + " fragment"
+}
+
+// article returns the description of the kind preceded by its article
+func (k FragmentKind) article() string {
+	s := k.String()
+	if strings.ContainsAny(strings.ToUpper(s[:1]), "AEIOU") {
+		return "an " + s
+	}
+	return "a " + s
+}
+
+// RAML versions
+const (
+	ramlVersion10 = "1.0"
+	ramlVersion08 = "0.8"
+)
+
+// parseHeader returns the RAML version and the kind of document declared
+// by the header line of a RAML document.
+// RAML 0.8 has no fragments, all of its documents are API definitions.
+func parseHeader(line string) (string, FragmentKind, error) {
+	line = strings.TrimSpace(line)
+	for _, version := range []string{ramlVersion10, ramlVersion08} {
+		rest := strings.TrimPrefix(line, "#%RAML "+version)
+		if rest == line || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+			continue
+		}
+		kind := FragmentKind(strings.TrimSpace(rest))
+		if version == ramlVersion08 && kind != FragmentAPI {
+			return "", "", fmt.Errorf("unknown RAML 0.8 document kind %q, RAML 0.8 has no fragments", string(kind))
+		}
+		for _, k := range fragmentKinds {
+			if k == kind {
+				return version, kind, nil
+			}
+		}
+		return "", "", fmt.Errorf("unknown RAML fragment kind %q", string(kind))
+	}
+	return "", "", fmt.Errorf("input file is not a RAML 1.0 or 0.8 file. Make sure the file starts with #%%RAML 1.0 or #%%RAML 0.8")
+}
+
+// headerReceiver is implemented by the documents of this package, which
+// are given the RAML version and the kind of document read from the header
+// of their file before being decoded, and refuse the kinds they can't be parsed from.
+type headerReceiver interface {
+	receiveHeader(version string, kind FragmentKind) error
+}
+
+// NamedExample holds the examples of a NamedExample fragment, by name.
+type NamedExample map[string]Example
+
+// Fragment is a RAML document of any kind: an API definition, a library or
+// one of the typed fragments meant to be included by them.
+type Fragment struct {
+	// Kind of the document, read from its header
+	Kind FragmentKind
+
+	// Value of the document, typed after its kind:
+	//   - *APIDefinition for an API definition, and for an overlay or
+	//     extension once merged into the API definition it extends
+	//   - *Library for a library
+	//   - *Type for a DataType
+	//   - *Trait for a Trait
+	//   - *ResourceType for a ResourceType
+	//   - *SecurityScheme for a SecurityScheme
+	//   - *NamedExample for a NamedExample
+	//   - *Documentation for a DocumentationItem
+	//   - *AnnotationType for an AnnotationTypeDeclaration
+	Value interface{}
+}
+
+// ParseFragmentFile parses a RAML file whatever its kind, which is read from its header.
+func ParseFragmentFile(filePath string, opts ...Option) (*Fragment, error) {
+	workDir, fileName := filepath.Split(filePath)
+	p := newParser(opts...)
+	f := new(Fragment)
+	_, err := p.run(func() ([]byte, error) {
+		return p.parseReadFile(workDir, fileName, f)
+	})
+	return f, err
+}
+
+func (f *Fragment) receiveHeader(version string, kind FragmentKind) error {
+	f.Kind = kind
+	switch kind {
+	case FragmentAPI, FragmentOverlay, FragmentExtension:
+		f.Value = new(APIDefinition)
+	case FragmentLibrary:
+		f.Value = new(Library)
+	case FragmentDataType:
+		f.Value = new(Type)
+	case FragmentTrait:
+		f.Value = new(Trait)
+	case FragmentResourceType:
+		f.Value = new(ResourceType)
+	case FragmentSecurityScheme:
+		f.Value = new(SecurityScheme)
+	case FragmentNamedExample:
+		f.Value = new(NamedExample)
+	case FragmentDocumentationItem:
+		f.Value = new(Documentation)
+	case FragmentAnnotationTypeDeclaration:
+		f.Value = new(AnnotationType)
+	default:
+		return fmt.Errorf("unknown RAML fragment kind %q", string(kind))
+	}
+	if hr, ok := f.Value.(headerReceiver); ok {
+		return hr.receiveHeader(version, kind)
+	}
+	return nil
+}
+
+// UnmarshalYAML decodes the document into the value of its kind
+func (f *Fragment) UnmarshalYAML(node *yaml.Node) error {
+	if f.Value == nil {
+		if err := f.receiveHeader(ramlVersion10, f.Kind); err != nil {
+			return err
+		}
+	}
+	return node.Decode(f.Value)
+}
+
+// PostProcess post processes the value of the fragment
+func (f *Fragment) PostProcess(workDir, fileName string) error {
+	p := newParser()
+	if err := f.postProcess(p, workDir, fileName); err != nil {
+		return err
+	}
+	return p.err()
+}
+
+func (f *Fragment) postProcess(p *parser, workDir, fileName string) error {
+	switch v := f.Value.(type) {
+	case postProcessor:
+		return v.postProcess(p, workDir, fileName)
+	case *Type:
+		return v.checkTypeExpressions(p, nil)
+	}
+	return nil
+}
+
+// includedFragmentKind returns the kind of fragment that can be included
+// under the given path, false if any kind can be.
+func includedFragmentKind(path []string) (FragmentKind, bool) {
+	if len(path) == 0 {
+		return "", false
+	}
+	switch path[len(path)-1] {
+	case "type", "items", "schema":
+		return FragmentDataType, true
+	case "examples":
+		return FragmentNamedExample, true
+	}
+	if len(path) < 2 {
+		return "", false
+	}
+	switch path[len(path)-2] {
+	case "types", "schemas", "properties", "body":
+		return FragmentDataType, true
+	case "traits":
+		return FragmentTrait, true
+	case "resourceTypes":
+		return FragmentResourceType, true
+	case "securitySchemes":
+		return FragmentSecurityScheme, true
+	case "annotationTypes":
+		return FragmentAnnotationTypeDeclaration, true
+	case "documentation":
+		return FragmentDocumentationItem, true
+	}
+	return "", false
+}
@@ -0,0 +1,87 @@
+package raml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsingFragments(t *testing.T) {
+	asserter := assert.New(t)
+
+	// every kind of fragment is parsed into its own value
+	fragments := map[string]FragmentKind{
+		"./testdata/fragments/api.raml":           FragmentAPI,
+		"./testdata/libraries/shared/common.raml": FragmentLibrary,
+		"./testdata/fragments/user.raml":          FragmentDataType,
+		"./testdata/fragments/paged.raml":         FragmentTrait,
+		"./testdata/fragments/collection.raml":    FragmentResourceType,
+		"./testdata/fragments/oauth.raml":         FragmentSecurityScheme,
+		"./testdata/fragments/users.raml":         FragmentNamedExample,
+		"./testdata/fragments/intro.raml":         FragmentDocumentationItem,
+		"./testdata/fragments/deprecated.raml":    FragmentAnnotationTypeDeclaration,
+	}
+	for file, kind := range fragments {
+		fragment, err := ParseFragmentFile(file)
+		asserter.NoError(err, file)
+		asserter.Equal(string(kind), string(fragment.Kind), file)
+	}
+
+	fragment, err := ParseFragmentFile("./testdata/fragments/user.raml")
+	asserter.NoError(err)
+	user := fragment.Value.(*Type)
+	asserter.Equal("object", user.Type)
+	asserter.Len(user.Properties, 2)
+
+	fragment, err = ParseFragmentFile("./testdata/fragments/paged.raml")
+	asserter.NoError(err)
+	asserter.Contains(fragment.Value.(*Trait).QueryParameters, "page")
+
+	fragment, err = ParseFragmentFile("./testdata/fragments/oauth.raml")
+	asserter.NoError(err)
+	asserter.Equal("OAuth 2.0", fragment.Value.(*SecurityScheme).Type)
+
+	fragment, err = ParseFragmentFile("./testdata/fragments/users.raml")
+	asserter.NoError(err)
+	asserter.Len(*fragment.Value.(*NamedExample), 2)
+
+	fragment, err = ParseFragmentFile("./testdata/fragments/intro.raml")
+	asserter.NoError(err)
+	asserter.Equal("Introduction", fragment.Value.(*Documentation).Title)
+
+	// unknown kinds are refused
+	_, err = ParseFragmentFile("./testdata/fragments/unknown.raml")
+	asserter.Error(err)
+	asserter.Equal(CodeInvalidHeader, err.(*Error).Diagnostics[0].Code)
+	asserter.Contains(err.Error(), `unknown RAML fragment kind "Fragment"`)
+
+	// as are documents of the wrong kind
+	err = ParseFile("./testdata/fragments/user.raml", new(APIDefinition))
+	asserter.Error(err)
+	asserter.Equal(CodeFragmentMismatch, err.(*Error).Diagnostics[0].Code)
+	asserter.Contains(err.Error(), "expected an API definition, found a DataType fragment")
+}
+
+func TestIncludingFragments(t *testing.T) {
+	asserter := assert.New(t)
+
+	apiDefinition := new(APIDefinition)
+	err := ParseFile("./testdata/fragments/api.raml", apiDefinition)
+	asserter.NoError(err)
+	asserter.Equal("Introduction", apiDefinition.Documentation[0].Title)
+	asserter.Equal("object", apiDefinition.Types["User"].Type)
+	asserter.Len(apiDefinition.Types["Member"].Examples, 2)
+	asserter.Contains(apiDefinition.Traits, "paged")
+	asserter.Equal("OAuth 2.0", apiDefinition.SecuritySchemes["oauth"].Type)
+	asserter.Contains(apiDefinition.Resources["/users"].Get.QueryParameters, "page")
+
+	// fragments included where another kind is expected are reported
+	diagnostics, err := ParseFileAll("./testdata/fragments/mismatch.raml", new(APIDefinition))
+	asserter.Error(err)
+	asserter.Len(diagnostics, 2)
+	for _, d := range diagnostics {
+		asserter.Equal(CodeFragmentMismatch, d.Code)
+	}
+	asserter.Contains(err.Error(), "included file paged.raml is a Trait fragment, expected a DataType fragment")
+	asserter.Contains(err.Error(), "included file user.raml is a DataType fragment, expected a Trait fragment")
+}
@@ -6,13 +6,18 @@ type Items struct {
 	Format string
 }
 
+// TypeExpression returns the syntax tree of the type expression of the items
+func (it Items) TypeExpression() (TypeExpression, error) {
+	return ParseTypeExpression(it.Type)
+}
+
 func newItems(i interface{}) Items {
 	var it Items
 	switch v := i.(type) {
 	case string:
 		it.Type = v
-	case map[interface{}]interface{}:
-		it.Type = v["type"].(string)
+	case map[string]interface{}:
+		it.Type = interfaceToString(v["type"])
 		if f, ok := v["format"].(string); ok {
 			it.Format = f
 		}
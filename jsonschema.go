@@ -265,5 +265,14 @@ func (p *property) toRAMLProperty() Property {
 }
 
 func isPropTypeSupported(p Property) bool {
-	return !p.IsBidimensionalArray() && !p.IsUnion()
+	if p.IsBidimensionalArray() || p.IsUnion() {
+		return false
+	}
+	// arrays of unions neither
+	items, ok := arrayItemsOf(p.TypeString())
+	if !ok {
+		return true
+	}
+	_, isUnion := items.(*UnionType)
+	return !isUnion
 }
@@ -2,7 +2,6 @@ package raml
 
 import (
 	"fmt"
-	"strings"
 )
 
 func newArraySchema(t *Type, typ, name string) JSONSchema {
@@ -51,5 +50,8 @@ func getArrayItemsType(t *Type, typ string) string {
 	if typ == "array" {
 		return fmt.Sprint(t.Items)
 	}
-	return strings.TrimSuffix(typ, "[]")
+	if items, ok := arrayItemsOf(typ); ok {
+		return items.String()
+	}
+	return typ
 }
@@ -2,7 +2,8 @@ package raml
 
 import (
 	"fmt"
-	"path/filepath"
+
+	"gopkg.in/yaml.v3"
 )
 
 // Library is used to combine any collection of data type declarations,
@@ -15,6 +16,7 @@ type Library struct {
 	ResourceTypes   map[string]ResourceType   `yaml:"resourceTypes"`
 	Traits          map[string]Trait          `yaml:"traits"`
 	SecuritySchemes map[string]SecurityScheme `yaml:"securitySchemes"`
+	AnnotationTypes map[string]AnnotationType `yaml:"annotationTypes"`
 	Uses            map[string]string         `yaml:"uses"`
 
 	// Describes the content or purpose of a specific library.
@@ -23,6 +25,36 @@ type Library struct {
 
 	Libraries map[string]*Library `yaml:"-"`
 	Filename  string              `yaml:"-"`
+
+	// The names of the types, traits and resource types declared by the library, in declaration order.
+	TypeOrder         []string `yaml:"-"`
+	TraitOrder        []string `yaml:"-"`
+	ResourceTypeOrder []string `yaml:"-"`
+
+	Annotations Annotations `yaml:",inline"`
+
+	// Where the library has been declared.
+	Position Position `yaml:",inline"`
+}
+
+// UnmarshalYAML decodes a library, keeping the order of its declarations
+func (l *Library) UnmarshalYAML(node *yaml.Node) error {
+	type clone Library
+	c := clone{Filename: l.Filename}
+	err := node.Decode(&c)
+	*l = Library(c)
+	l.TypeOrder = mappingKeys(mappingValue(node, "types"), nil)
+	l.TraitOrder = mappingKeys(mappingValue(node, "traits"), nil)
+	l.ResourceTypeOrder = mappingKeys(mappingValue(node, "resourceTypes"), nil)
+	return err
+}
+
+// receiveHeader only accepts libraries
+func (l *Library) receiveHeader(version string, kind FragmentKind) error {
+	if kind != FragmentLibrary {
+		return fmt.Errorf("expected a library, found %s", kind.article())
+	}
+	return nil
 }
 
 // PostProcess doing additional processing
@@ -31,19 +63,35 @@ type Library struct {
 // - setting some additional values not exist in the .raml
 // - allocate map fields
 func (l *Library) PostProcess(workDir, fileName string) error {
-	//TODO: what do we do when two libraries have a circular dependency?
-	if !isURL(fileName) {
-		workDir = filepath.Join(workDir, filepath.Dir(fileName))
+	p := newParser()
+	if err := l.postProcess(p, workDir, fileName); err != nil {
+		return err
 	}
+	return p.err()
+}
+
+func (l *Library) postProcess(p *parser, workDir, fileName string) error {
+	// used libraries are relative to the library
+	workDir = fileDir(workDir, fileName)
 	l.Libraries = map[string]*Library{}
-	for name, path := range l.Uses {
-		lib := &Library{Filename: path}
-		if _, err := ParseReadFile(workDir, path, lib); err != nil {
-			return fmt.Errorf("l.PostProcess() failed to parse library	name=%v, path=%v, err=%v",
-				name, path, err)
+	for _, name := range sortedKeys(l.Uses) {
+		lib, err := p.parseLibrary(workDir, l.Uses[name], []string{"uses", name})
+		if err != nil {
+			return err
+		}
+		if lib != nil {
+			l.Libraries[name] = lib
 		}
-		l.Libraries[name] = lib
+	}
 
+	// security schemes
+	for _, name := range sortedKeys(l.SecuritySchemes) {
+		s := l.SecuritySchemes[name]
+		s.Name = name
+		if err := s.check(p, []string{"securitySchemes", name}); err != nil {
+			return err
+		}
+		l.SecuritySchemes[name] = s
 	}
 
 	// traits
@@ -52,9 +100,15 @@ func (l *Library) PostProcess(workDir, fileName string) error {
 		l.Traits[name] = t
 	}
 
-	// resource types
-	for name, rt := range l.ResourceTypes {
-		err := rt.postProcess(name, l.Traits, nil)
+	// resource types, which can apply the traits of the libraries used by the library
+	traits := make(map[string]Trait, len(l.Traits))
+	for name, t := range l.Traits {
+		traits[name] = t
+	}
+	traits = allTraits(traits, l.Libraries)
+	for _, name := range sortedKeys(l.ResourceTypes) {
+		rt := l.ResourceTypes[name]
+		err := rt.postProcess(p, name, traits, nil)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,84 @@
+package raml
+
+import (
+	"net/url"
+	"path/filepath"
+	"sync"
+)
+
+// LibraryCache holds parsed libraries by their resolved location, so that a
+// library used several times is parsed once and is the same *Library
+// wherever it is used.
+// Every parse has a cache of its own, unless one is shared between parses
+// with WithLibraryCache. The cached libraries are handed out as they are,
+// they must not be modified.
+//
+// A cache must only be shared between parses reading their files with the
+// same loader, as a location then always holds the same library.
+type LibraryCache struct {
+	mu        sync.Mutex
+	libraries map[string]*Library
+}
+
+// NewLibraryCache creates an empty library cache.
+func NewLibraryCache() *LibraryCache {
+	return &LibraryCache{libraries: map[string]*Library{}}
+}
+
+// Get returns the library parsed from the given path or URL, if it has been cached.
+func (c *LibraryCache) Get(location string) (*Library, bool) {
+	return c.get(canonicalLocation(location))
+}
+
+// Len returns the number of cached libraries.
+func (c *LibraryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.libraries)
+}
+
+// Clear removes all the cached libraries, so that they are parsed again.
+func (c *LibraryCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.libraries = map[string]*Library{}
+}
+
+func (c *LibraryCache) get(key string) (*Library, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lib, ok := c.libraries[key]
+	return lib, ok
+}
+
+// add caches lib under key, unless another parse has cached it meanwhile,
+// in which case the library already cached is returned.
+func (c *LibraryCache) add(key string, lib *Library) *Library {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.libraries[key]; ok {
+		return cached
+	}
+	if c.libraries == nil {
+		c.libraries = map[string]*Library{}
+	}
+	c.libraries[key] = lib
+	return lib
+}
+
+// canonicalLocation returns the key under which the library at location is cached:
+// the normalized URL, or the absolute and cleaned file path.
+func canonicalLocation(location string) string {
+	if isURL(location) {
+		u, err := url.Parse(location)
+		if err != nil {
+			return location
+		}
+		return u.ResolveReference(&url.URL{}).String()
+	}
+	abs, err := filepath.Abs(location)
+	if err != nil {
+		return filepath.Clean(location)
+	}
+	return abs
+}
@@ -1,6 +1,8 @@
 package raml
 
 import (
+	"path/filepath"
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -31,6 +33,7 @@ func TestLibraries(t *testing.T) {
 			file := files.ResourceTypes["file"]
 			So(file.Get, ShouldNotBeNil)
 			So(file.Get.Headers, ShouldContainKey, HTTPHeader("drm-key"))
+			So(file.Get.Headers, ShouldContainKey, HTTPHeader("file-version"))
 
 			// second level
 			So(files.Libraries, ShouldContainKey, "file-type")
@@ -47,6 +50,12 @@ func TestLibraries(t *testing.T) {
 			So(files.Get.Headers["drm-key"].Required, ShouldBeFalse)
 		})
 
+		Convey("using the traits of a library used by a library", func() {
+			documents := apiDef.Resources["/documents"]
+			So(documents.Get, ShouldNotBeNil)
+			So(documents.Get.Headers, ShouldContainKey, HTTPHeader("file-version"))
+		})
+
 		Convey("proper variable name", func() {
 			r := apiDef.Resources["/links"]
 
@@ -56,3 +65,74 @@ func TestLibraries(t *testing.T) {
 
 	})
 }
+
+func TestCircularDependencies(t *testing.T) {
+	Convey("circular dependencies", t, func() {
+		Convey("between libraries", func() {
+			err := ParseFile("./testdata/cycles/a.raml", new(APIDefinition))
+			So(err, ShouldNotBeNil)
+
+			d := err.(*Error).Diagnostics[0]
+			So(d.Code, ShouldEqual, CodeCircularLibrary)
+			So(d.Path, ShouldResemble, []string{"uses", "root"})
+			So(d.Message, ShouldEqual, "circular library dependency: "+strings.Join([]string{
+				filepath.Join("testdata", "cycles", "a.raml"),
+				filepath.Join("testdata", "cycles", "lib", "b.raml"),
+				filepath.Join("testdata", "cycles", "a.raml"),
+			}, " -> "))
+		})
+
+		Convey("between included files", func() {
+			err := ParseFile("./testdata/cycles/includes.raml", new(APIDefinition))
+			So(err, ShouldNotBeNil)
+
+			d := err.(*Error).Diagnostics[0]
+			So(d.Code, ShouldEqual, CodeCircularInclude)
+			So(d.Position.File, ShouldEqual, filepath.Join("testdata", "cycles", "y.raml"))
+			So(d.Message, ShouldEqual, "circular include: "+strings.Join([]string{
+				filepath.Join("testdata", "cycles", "x.raml"),
+				filepath.Join("testdata", "cycles", "y.raml"),
+				filepath.Join("testdata", "cycles", "x.raml"),
+			}, " -> "))
+		})
+	})
+}
+
+func TestLibraryCache(t *testing.T) {
+	Convey("library cache", t, func() {
+		Convey("a library used twice is parsed once", func() {
+			apiDef := new(APIDefinition)
+			err := ParseFile("./testdata/libraries/shared/api.raml", apiDef)
+			So(err, ShouldBeNil)
+
+			users := apiDef.Libraries["users"]
+			orders := apiDef.Libraries["orders"]
+			So(users.Libraries, ShouldContainKey, "common")
+			So(orders.Libraries, ShouldContainKey, "common")
+			So(users.Libraries["common"], ShouldPointTo, orders.Libraries["common"])
+			So(users.Libraries["common"].Types, ShouldContainKey, "Id")
+		})
+
+		Convey("shared between parses", func() {
+			cache := NewLibraryCache()
+
+			first := new(APIDefinition)
+			err := ParseFileWith("./testdata/libraries/shared/api.raml", first, WithLibraryCache(cache))
+			So(err, ShouldBeNil)
+			So(cache.Len(), ShouldEqual, 3)
+
+			common, ok := cache.Get(filepath.Join("testdata", "libraries", "shared", "orders", "..", "common.raml"))
+			So(ok, ShouldBeTrue)
+			So(common, ShouldPointTo, first.Libraries["users"].Libraries["common"])
+
+			second := new(APIDefinition)
+			err = ParseFileWith("./testdata/libraries/shared/api.raml", second, WithLibraryCache(cache))
+			So(err, ShouldBeNil)
+			So(second.Libraries["users"], ShouldPointTo, first.Libraries["users"])
+			So(second.Libraries["orders"], ShouldPointTo, first.Libraries["orders"])
+
+			cache.Clear()
+			So(cache.Len(), ShouldEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,145 @@
+package raml
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultHTTPTimeout is the timeout of the requests made by the default loader.
+const DefaultHTTPTimeout = 30 * time.Second
+
+// A Loader reads the RAML documents to parse and the files they include or use.
+type Loader interface {
+	// Load returns the contents found at location, which is either an URL or
+	// a file path already joined with the directory of the document referencing it.
+	Load(ctx context.Context, location string) ([]byte, error)
+}
+
+// DefaultLoader returns the loader used when none is given:
+// URLs are fetched with an HTTPLoader and anything else is read from the file system.
+func DefaultLoader() Loader {
+	return defaultLoader{
+		http: &HTTPLoader{Timeout: DefaultHTTPTimeout},
+	}
+}
+
+type defaultLoader struct {
+	http *HTTPLoader
+}
+
+func (l defaultLoader) Load(ctx context.Context, location string) ([]byte, error) {
+	if isURL(location) {
+		return l.http.Load(ctx, location)
+	}
+	return FileLoader{}.Load(ctx, location)
+}
+
+// FileLoader reads files from the operating system's file system.
+type FileLoader struct{}
+
+// Load reads the file at location
+func (FileLoader) Load(_ context.Context, location string) ([]byte, error) {
+	contents, err := ioutil.ReadFile(location)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file %s (Error: %s)", location, err.Error())
+	}
+	return contents, nil
+}
+
+// FSLoader returns a loader reading files from fsys, e.g. an embed.FS.
+// Locations are interpreted relatively to the root of fsys.
+func FSLoader(fsys fs.FS) Loader {
+	return fsLoader{fsys: fsys}
+}
+
+type fsLoader struct {
+	fsys fs.FS
+}
+
+func (l fsLoader) Load(_ context.Context, location string) ([]byte, error) {
+	name := fsPath(location)
+	if !fs.ValidPath(name) {
+		return nil, fmt.Errorf("could not read file %s (Error: invalid path)", location)
+	}
+	contents, err := fs.ReadFile(l.fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file %s (Error: %s)", location, err.Error())
+	}
+	return contents, nil
+}
+
+// MapLoader is an in-memory loader, mapping locations to their contents.
+// Locations are cleaned before being looked up, so "./api.raml" and
+// "api.raml" both find the "api.raml" entry.
+type MapLoader map[string][]byte
+
+// Load returns the contents stored for location
+func (l MapLoader) Load(_ context.Context, location string) ([]byte, error) {
+	if contents, ok := l[location]; ok {
+		return contents, nil
+	}
+	if contents, ok := l[fsPath(location)]; ok {
+		return contents, nil
+	}
+	return nil, fmt.Errorf("could not read file %s (Error: not found)", location)
+}
+
+// HTTPLoader fetches documents over HTTP(S).
+type HTTPLoader struct {
+	// Client used to send the requests, http.DefaultClient if nil.
+	Client *http.Client
+
+	// Header added to every request, e.g. to authenticate.
+	Header http.Header
+
+	// Timeout of a single request, none if zero.
+	Timeout time.Duration
+}
+
+// Load fetches the document at the location URL
+func (l *HTTPLoader) Load(ctx context.Context, location string) ([]byte, error) {
+	if l.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range l.Header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	client := l.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("could not fetch %s (Error: %s)", location, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// fsPath converts a location to an io/fs path: slash separated,
+// cleaned and without any leading "./" or "/"
+func fsPath(location string) string {
+	p := path.Clean(filepath.ToSlash(location))
+	return strings.TrimPrefix(p, "/")
+}
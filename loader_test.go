@@ -0,0 +1,87 @@
+package raml
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLoaders(t *testing.T) {
+	Convey("loaders", t, func() {
+		Convey("in-memory documents", func() {
+			loader := MapLoader{
+				"specs/api.raml": []byte("#%RAML 1.0\ntitle: In memory\nuses:\n  lib: lib.raml\n" +
+					"types:\n  User: !include user.raml\n"),
+				"specs/lib.raml":  []byte("#%RAML 1.0 Library\ntraits:\n  paged:\n    description: paged\n"),
+				"specs/user.raml": []byte("#%RAML 1.0 DataType\ntype: object\n"),
+			}
+
+			apiDef := new(APIDefinition)
+			So(ParseFileWith("./specs/api.raml", apiDef, WithLoader(loader)), ShouldBeNil)
+			So(apiDef.Title, ShouldEqual, "In memory")
+			So(apiDef.Types["User"].Type, ShouldEqual, "object")
+			So(apiDef.Libraries["lib"].Traits, ShouldContainKey, "paged")
+		})
+
+		Convey("file systems", func() {
+			apiDef := new(APIDefinition)
+			So(ParseFileWith("simple_with_lib.raml", apiDef, WithLoader(FSLoader(os.DirFS("testdata")))), ShouldBeNil)
+			So(apiDef.Libraries["files"].Libraries, ShouldContainKey, "file-type")
+
+			fsys := fstest.MapFS{"api.raml": {Data: []byte("#%RAML 1.0\ntitle: From fs\n")}}
+			apiDef = new(APIDefinition)
+			So(ParseFileWith("/api.raml", apiDef, WithLoader(FSLoader(fsys))), ShouldBeNil)
+			So(apiDef.Title, ShouldEqual, "From fs")
+
+			_, err := FSLoader(fsys).Load(context.Background(), "../api.raml")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("HTTP", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				switch r.URL.Path {
+				case "/specs/api.raml":
+					w.Write([]byte("#%RAML 1.0\ntitle: Remote\ntypes:\n  User: !include types/user.raml\n"))
+				case "/specs/types/user.raml":
+					w.Write([]byte("#%RAML 1.0 DataType\ntype: string\n"))
+				case "/slow.raml":
+					time.Sleep(100 * time.Millisecond)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			loader := &HTTPLoader{
+				Client:  server.Client(),
+				Header:  http.Header{"Authorization": {"Bearer token"}},
+				Timeout: 20 * time.Millisecond,
+			}
+
+			apiDef := new(APIDefinition)
+			So(ParseFileWith(server.URL+"/specs/api.raml", apiDef, WithLoader(loader)), ShouldBeNil)
+			So(apiDef.Title, ShouldEqual, "Remote")
+			So(apiDef.Types["User"].Type, ShouldEqual, "string")
+
+			_, err := loader.Load(context.Background(), server.URL+"/missing.raml")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "404")
+
+			_, err = loader.Load(context.Background(), server.URL+"/slow.raml")
+			So(err, ShouldNotBeNil)
+
+			_, err = (&HTTPLoader{}).Load(context.Background(), server.URL+"/specs/api.raml")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,444 @@
+package raml
+
+import (
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// This file implements the inheritance of resource types and traits,
+// which is done on the nodes of the documents before they are decoded,
+// following the algorithm of merging nodes of the RAML 1.0 specification:
+//   - properties of an object missing from the receiver are copied from the template,
+//     the ones present in both are merged
+//   - arrays are merged by adding the elements of the template missing from the receiver
+//   - a scalar of the receiver always takes precedence over the one of the template
+//   - an optional property of the template, ending with a question mark, is only merged
+//     when the receiver has the property, either declared by itself or inherited from
+//     any of its templates; `\?` escapes a question mark ending a property name
+//
+// A method inherits, from the highest precedence to the lowest:
+//   - its own properties
+//   - the traits applied to it, then the ones applied to its resource
+//   - for each resource type of the resource, from the nearest to the farthest:
+//     the method of the resource type, the traits applied to that method,
+//     then the ones applied to the resource type
+
+// keys of a trait that are not inherited by the methods
+var traitOwnKeys = []string{"usage"}
+
+// keys of a resource type that are not inherited by the resources,
+// methods being inherited separately
+var resourceTypeOwnKeys = []string{"usage", "type", "is", "get", "post", "put", "patch", "head", "delete", "options",
+	"get?", "post?", "put?", "patch?", "head?", "delete?", "options?"}
+
+// keys of a resource type method that are not inherited by the methods
+var methodOwnKeys = []string{"is"}
+
+// declarations holds all that resources can inherit from: the resource types
+// and traits of an API and of the libraries it uses, by their qualified name.
+type declarations struct {
+	resourceTypes map[string]ResourceType
+	traits        map[string]Trait
+	libraries     map[string]*Library
+
+	// the default media types of the bodies declared without media type
+	mediaTypes []string
+
+	// all annotation types, by qualified name, telling the annotations
+	// which only apply to the declarations themselves
+	annotationTypes map[string]AnnotationType
+
+	// files of the nodes of the parse, to which the copies of templates are added
+	files nodeFiles
+}
+
+// scopedName returns the qualified name of the declaration named name,
+// as referred to by the declaration qualified as from.
+// Inside a library, a name refers to the declarations of that library first.
+func scopedName(from, name string, declared func(string) bool) string {
+	if i := strings.LastIndex(from, "."); i >= 0 {
+		if qualified := from[:i+1] + name; declared(qualified) {
+			return qualified
+		}
+	}
+	return name
+}
+
+// library returns the library in which the declaration qualified as name
+// has been declared, nil for the ones of the API itself
+func (decls declarations) library(name string) (*Library, string) {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return nil, ""
+	}
+	return decls.libraries[name[:i]], name[:i]
+}
+
+// lookupTrait returns the trait named name, as referred to by the declaration qualified as from
+func (decls declarations) lookupTrait(from, name string) (Trait, bool) {
+	t, ok := decls.traits[scopedName(from, name, func(qualified string) bool {
+		_, ok := decls.traits[qualified]
+		return ok
+	})]
+	return t, ok
+}
+
+// ownAnnotation checks whether an annotation applied to the declaration qualified as from
+// applies to the declaration only: its annotation type can't be applied to the nodes
+// inheriting the declaration, e.g. it's restricted to resource types and traits.
+func (decls declarations) ownAnnotation(key, from string, target AnnotationTarget) bool {
+	if target == "" || !annotationNameRegexp.MatchString(key) {
+		return false
+	}
+	a, ok := decls.annotationTypes[scopedName(from, AnnotationName(key).name(), func(qualified string) bool {
+		_, ok := decls.annotationTypes[qualified]
+		return ok
+	})]
+	return ok && !a.allows(target)
+}
+
+// instantiate returns a copy of a template node, ready to be merged into a node of target:
+// without the keys which are not inherited nor the annotations which only apply to the
+// declaration, with its parameters substituted and the names of the types of the library
+// it's been declared in qualified.
+// The target is empty for templates which are not declarations of their own, such as the
+// methods of resource types, all of their annotations being inherited.
+func (decls declarations) instantiate(template *yaml.Node, file, from string, dicts map[string]interface{},
+	ownKeys []string, target AnnotationTarget) *yaml.Node {
+	node := decls.files.copyNode(template, file)
+	if node.Kind == yaml.MappingNode {
+		content := node.Content[:0:0]
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if !containsString(ownKeys, key) && !decls.ownAnnotation(key, from, target) {
+				content = append(content, node.Content[i], node.Content[i+1])
+			}
+		}
+		node.Content = content
+	}
+	substituteNode(node, dicts)
+	expandBodies(node, decls.mediaTypes, decls.files)
+	if lib, libName := decls.library(from); lib != nil {
+		qualifyTypeNames(node, lib, libName)
+	}
+	return node
+}
+
+// bodyDeclarationKeys are the keys of which values are type declarations,
+// in which no body is declared
+var bodyDeclarationKeys = []string{"types", "properties", "type", "items", "example", "examples", "annotationTypes"}
+
+// expandBodies declares the bodies of a node and of its descendants which
+// are declared without media type for each of the default media types,
+// for bodies to be merged by media type whatever the way they are declared.
+func expandBodies(node *yaml.Node, mediaTypes []string, files nodeFiles) {
+	if len(mediaTypes) == 0 || node == nil {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if node.Kind != yaml.MappingNode {
+			break
+		}
+		if name, _ := optionalKey(key.Value); name == "body" {
+			if isBodyShorthand(value) {
+				node.Content[i+1] = bodiesForMediaTypes(value, mediaTypes, files)
+			}
+			continue
+		}
+		if !containsString(bodyDeclarationKeys, key.Value) {
+			expandBodies(value, mediaTypes, files)
+		}
+	}
+	if node.Kind == yaml.SequenceNode {
+		for _, item := range node.Content {
+			expandBodies(item, mediaTypes, files)
+		}
+	}
+}
+
+// isBodyShorthand checks whether a body node is declared without media type
+func isBodyShorthand(node *yaml.Node) bool {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return len(node.Content) > 0 && !hasMediaTypeKeys(node)
+	case yaml.ScalarNode:
+		return !isNullNode(node)
+	}
+	return node.Kind == yaml.SequenceNode
+}
+
+// bodiesForMediaTypes returns a body node declaring the given body for each media type
+func bodiesForMediaTypes(body *yaml.Node, mediaTypes []string, files nodeFiles) *yaml.Node {
+	bodies := files.newNode(yaml.MappingNode, "", body)
+	for i, mediaType := range mediaTypes {
+		value := body
+		if i > 0 {
+			value = files.copyNode(body, files[body])
+		}
+		bodies.Content = append(bodies.Content, files.scalarNode(mediaType, body), value)
+	}
+	return bodies
+}
+
+// mergeTemplates merges templates into the receiver node,
+// from the one of highest precedence to the one of lowest.
+// An optional node of a template applies when the receiver has the node once
+// all templates are merged, whether declared by itself or inherited from any
+// of the templates: the shape of the receiver is computed first, without the
+// optional nodes, and then checked while merging.
+func mergeTemplates(receiver *yaml.Node, templates []*yaml.Node) {
+	shape := cloneNode(receiver)
+	for _, template := range templates {
+		mergeNodes(shape, cloneNode(template), nil)
+	}
+	for _, template := range templates {
+		mergeNodes(receiver, template, shape)
+	}
+}
+
+// mergeNodes merges the template node into the receiver node.
+// Optional nodes of the template are applied when the receiver, or its shape, has them.
+func mergeNodes(receiver, template, shape *yaml.Node) {
+	switch {
+	case receiver.Kind == yaml.MappingNode && template.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(template.Content); i += 2 {
+			key, value := template.Content[i], template.Content[i+1]
+			name, optional := optionalKey(key.Value)
+			j := mappingIndex(receiver, name)
+			if optional && j < 0 && mappingIndex(shape, name) < 0 {
+				continue
+			}
+			switch {
+			case j < 0:
+				key.Value = name
+				filterOptionalNodes(value, mappingValue(shape, name))
+				receiver.Content = append(receiver.Content, key, value)
+			case isNullNode(receiver.Content[j+1]):
+				// a property without value takes the one of the template
+				filterOptionalNodes(value, mappingValue(shape, name))
+				receiver.Content[j+1] = value
+			default:
+				mergeNodes(receiver.Content[j+1], value, mappingValue(shape, name))
+			}
+		}
+	case receiver.Kind == yaml.SequenceNode && template.Kind == yaml.SequenceNode:
+		for _, item := range template.Content {
+			if !containsNode(receiver.Content, item) {
+				receiver.Content = append(receiver.Content, item)
+			}
+		}
+	}
+	// scalars of the receiver take precedence
+}
+
+// optionalKey returns the name of a property of a template,
+// and whether it's optional
+func optionalKey(key string) (string, bool) {
+	switch {
+	case strings.HasSuffix(key, `\?`):
+		return key[:len(key)-2] + "?", false
+	case strings.HasSuffix(key, "?"):
+		return key[:len(key)-1], true
+	}
+	return key, false
+}
+
+// filterOptionalNodes removes the optional properties of a template node being
+// copied as is into the receiver, unless its shape has them
+func filterOptionalNodes(node, shape *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		for _, child := range node.Content {
+			filterOptionalNodes(child, nil)
+		}
+		return
+	}
+	content := node.Content[:0:0]
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		name, optional := optionalKey(key.Value)
+		if optional && mappingIndex(shape, name) < 0 {
+			continue
+		}
+		key.Value = name
+		filterOptionalNodes(value, mappingValue(shape, name))
+		content = append(content, key, value)
+	}
+	node.Content = content
+}
+
+// mappingIndex returns the index of the key in a mapping node, -1 if not found
+func mappingIndex(node *yaml.Node, key string) int {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return -1
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mappingKeys returns the keys of a mapping node in declaration order,
+// only the ones keep returns true for when given
+func mappingKeys(node *yaml.Node, keep func(string) bool) []string {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	var keys []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if keep == nil || keep(node.Content[i].Value) {
+			keys = append(keys, node.Content[i].Value)
+		}
+	}
+	return keys
+}
+
+// mappingValue returns the value of the key in a mapping node, nil if not found
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if i := mappingIndex(node, key); i >= 0 {
+		return node.Content[i+1]
+	}
+	return nil
+}
+
+func isNullNode(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null"
+}
+
+// toMappingNode turns a node without value, such as a method declared
+// without any property, into an empty mapping templates can be merged into
+func toMappingNode(node *yaml.Node) {
+	if isNullNode(node) {
+		node.Kind, node.Tag, node.Value = yaml.MappingNode, "!!map", ""
+	}
+}
+
+// containsNode checks if nodes has a node equal to node
+func containsNode(nodes []*yaml.Node, node *yaml.Node) bool {
+	for _, n := range nodes {
+		if equalNodes(n, node) {
+			return true
+		}
+	}
+	return false
+}
+
+// equalNodes compares the values of two nodes, regardless of their position
+func equalNodes(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if !equalNodes(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// copyNode returns a deep copy of node.
+// The copies keep the position of the originals, the ones of which file is
+// no longer known being attributed to file.
+func (f nodeFiles) copyNode(node *yaml.Node, file string) *yaml.Node {
+	return deepCopyNode(node, func(original, c *yaml.Node) {
+		f[c] = f.positionOr(original, file).File
+	})
+}
+
+// cloneNode returns a deep copy of node, which isn't given any position
+func cloneNode(node *yaml.Node) *yaml.Node {
+	return deepCopyNode(node, func(original, c *yaml.Node) {})
+}
+
+// deepCopyNode returns a deep copy of node, calling copied for each of the copies
+func deepCopyNode(node *yaml.Node, copied func(original, c *yaml.Node)) *yaml.Node {
+	c := *node
+	c.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		c.Content[i] = deepCopyNode(child, copied)
+	}
+	copied(node, &c)
+	return &c
+}
+
+// paramRe matches a scalar only made of a parameter
+var paramRe = regexp.MustCompile(`^<<\s*([^<>|]+?)\s*>>$`)
+
+// substituteNode substitutes the parameters of a template node and all of its
+// descendants, keys included.
+// A scalar only made of a parameter which value isn't a string is replaced
+// by the value itself.
+func substituteNode(node *yaml.Node, dicts map[string]interface{}) {
+	walkNodes(node, func(n *yaml.Node) {
+		if n.Kind != yaml.ScalarNode || !strings.Contains(n.Value, "<<") {
+			return
+		}
+		if m := paramRe.FindStringSubmatch(n.Value); m != nil {
+			if val, ok := dicts[m[1]]; ok {
+				if _, isString := val.(string); !isString {
+					var v yaml.Node
+					if err := v.Encode(val); err == nil {
+						v.Line, v.Column = n.Line, n.Column
+						*n = v
+						return
+					}
+				}
+			}
+		}
+		n.Value = substituteString(n.Value, dicts)
+		if n.Style == 0 {
+			// resolve the tag of the substituted value
+			n.Tag = ""
+		}
+	})
+}
+
+// keys of which values are type expressions
+var typeKeys = []string{"type", "items", "schema"}
+
+// qualifyTypeNames prefixes the names of the types declared by the library
+// named libName, which are used by a template of that library.
+func qualifyTypeNames(node *yaml.Node, lib *Library, libName string) {
+	qualify := func(n *yaml.Node) {
+		if n.Kind != yaml.ScalarNode {
+			return
+		}
+		// schemas and invalid expressions are left as they are
+		expr, ok := typeExpressionOf(n.Value)
+		if !ok {
+			return
+		}
+		qualified := false
+		walkTypeExpression(expr, func(e TypeExpression) {
+			if named, ok := e.(*NamedType); ok && named.Library == "" {
+				if _, ok := lib.Types[named.Name]; ok {
+					named.Library = libName
+					qualified = true
+				}
+			}
+		})
+		if qualified {
+			n.Value = expr.String()
+		}
+	}
+	walkNodes(node, func(n *yaml.Node) {
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i].Value, n.Content[i+1]
+			switch {
+			case containsString(typeKeys, key):
+				qualify(value)
+			case key == "properties" && value.Kind == yaml.MappingNode:
+				for j := 1; j < len(value.Content); j += 2 {
+					qualify(value.Content[j])
+				}
+			}
+		}
+	})
+}
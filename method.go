@@ -3,6 +3,8 @@ package raml
 import (
 	"fmt"
 	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 // Method are operations that are performed on a resource
@@ -12,8 +14,40 @@ type Method struct {
 
 	Name string
 
-	// name of the resource type this method inherited
-	resourceTypeName string
+	// Where the method has been declared.
+	// Methods only declared by a resource type are positioned at their declaration in the resource type.
+	Position Position `yaml:",inline"`
+
+	// Where the traits and resource type methods this method inherits from have been declared.
+	InheritedFrom []Position `yaml:"-"`
+
+	// The ways the method is secured, any of them being enough: the security schemes
+	// the method is secured by, else the ones of its resource, else the ones of the API.
+	// The headers, query parameters and responses describing the schemes are merged into the method.
+	Security []SecurityRequirement `yaml:"-"`
+
+	// The names of the query parameters and headers, and the status codes
+	// of the responses of the method, in declaration order.
+	QueryParameterOrder []string     `yaml:"-"`
+	HeaderOrder         []HTTPHeader `yaml:"-"`
+	ResponseOrder       []HTTPCode   `yaml:"-"`
+}
+
+// UnmarshalYAML decodes a method, keeping the order of its parameters and responses
+func (m *Method) UnmarshalYAML(node *yaml.Node) error {
+	type clone Method
+	c := clone{}
+	err := node.Decode(&c)
+	if err == nil {
+		err = decodeSecuredBy(node, &c.SecuredBy)
+	}
+	*m = Method(c)
+	m.QueryParameterOrder = mappingKeys(mappingValue(node, "queryParameters"), nil)
+	m.HeaderOrder = headerOrder(node)
+	for _, code := range mappingKeys(mappingValue(node, "responses"), nil) {
+		m.ResponseOrder = append(m.ResponseOrder, HTTPCode(code))
+	}
+	return err
 }
 
 type methodProps struct {
@@ -61,187 +95,21 @@ type methodProps struct {
 	SecuredBy []DefinitionChoice `yaml:"securedBy"`
 }
 
-func newMethod(name string) *Method {
-	return &Method{
-		Name: name,
-	}
-}
-
 // doing post processing that can't be done by YAML parser
-func (m *Method) postProcess(r *Resource, name string, traitsMap map[string]Trait, apiDef *APIDefinition) error {
+func (m *Method) postProcess(r *Resource, name string, mediaTypes []string) {
 	m.Name = name
-	err := m.inheritFromTraits(r, append(r.Is, m.Is...), traitsMap, apiDef)
-	if err != nil {
-		return err
-	}
 	r.Methods = append(r.Methods, m)
 
 	// post process the responses
 	responses := make(map[HTTPCode]Response)
 	for code, resp := range m.Responses {
-		resp.postProcess()
+		resp.postProcess(mediaTypes)
 		responses[code] = resp
 	}
 	m.Responses = responses
 
 	// post process request body
-	m.Bodies.postProcess()
-
-	return nil
-}
-
-// inherit from resource type
-// fields need to be inherited:
-// - description
-// - response
-func (m *Method) inheritFromResourceType(r *Resource, rtm *Method, apiDef *APIDefinition) {
-	if rtm == nil {
-		return
-	}
-	dicts := initResourceTypeDicts(r, r.Type.Parameters)
-
-	// inherit description
-	m.Description = substituteParams(m.Description, rtm.Description, dicts)
-
-	// inherit display name
-	m.DisplayName = substituteParams(m.DisplayName, rtm.DisplayName, dicts)
-
-	// inherit bodies
-	m.Bodies.inherit(rtm.Bodies, dicts, m.resourceTypeName, apiDef)
-
-	// inherit headers
-	m.inheritHeaders(rtm.Headers, dicts)
-
-	// inherit query params
-	m.inheritQueryParams(rtm.QueryParameters, dicts)
-
-	// inherit response
-	m.inheritResponses(rtm.Responses, dicts, apiDef)
-
-	// inherit protocols
-	m.inheritProtocols(rtm.Protocols)
-}
-
-// inherit from all traits, inherited traits are:
-// - resource level trait
-// - method trait
-func (m *Method) inheritFromTraits(r *Resource, is []DefinitionChoice, traitsMap map[string]Trait,
-	apiDef *APIDefinition) error {
-	for _, tDef := range is {
-		// acquire traits object
-		t, ok := traitsMap[tDef.Name]
-		if !ok {
-			return fmt.Errorf("invalid traits name:%v", tDef.Name)
-		}
-
-		if err := m.inheritFromATrait(r, &t, tDef.Parameters, apiDef); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// inherit from a trait
-// dicts is map of trait parameters values
-func (m *Method) inheritFromATrait(r *Resource, t *Trait, dicts map[string]interface{},
-	apiDef *APIDefinition) error {
-	dicts = initTraitDicts(r, m, dicts)
-
-	m.Description = substituteParams(m.Description, t.Description, dicts)
-
-	m.Bodies.inherit(t.Bodies, dicts, m.resourceTypeName, apiDef)
-
-	m.inheritHeaders(t.Headers, dicts)
-
-	m.inheritResponses(t.Responses, dicts, apiDef)
-
-	m.inheritQueryParams(t.QueryParameters, dicts)
-
-	m.inheritProtocols(t.Protocols)
-
-	// optional bodies
-	// optional headers
-	// optional responses
-	// optional query parameters
-	return nil
-}
-
-// inheritHeaders inherit method's headers from parent headers.
-// parent headers could be from resource type or a trait
-func (m *Method) inheritHeaders(parents map[HTTPHeader]Header, dicts map[string]interface{}) {
-	m.Headers = inheritHeaders(m.Headers, parents, dicts)
-}
-
-// inheritHeaders inherits headers from parents to childs
-func inheritHeaders(childs, parents map[HTTPHeader]Header, dicts map[string]interface{}) map[HTTPHeader]Header {
-	if len(childs) == 0 {
-		childs = map[HTTPHeader]Header{}
-	}
-
-	for name, parent := range parents {
-		h, ok := childs[name]
-		if !ok {
-			if optionalTraitProperty(string(name)) { // don't inherit optional property if not exist
-				continue
-			}
-			h = Header{}
-		}
-		parent.Name = string(name)
-		np := NamedParameter(h)
-		np.inherit(NamedParameter(parent), dicts)
-		childs[name] = Header(np)
-	}
-	return childs
-}
-
-// inheritQueryParams inherit method's query params from parent query params.
-// parent query params could be from resource type or a trait
-func (m *Method) inheritQueryParams(parents map[string]NamedParameter, dicts map[string]interface{}) {
-	if len(m.QueryParameters) == 0 {
-		m.QueryParameters = map[string]NamedParameter{}
-	}
-	for name, parent := range parents {
-		qp, ok := m.QueryParameters[name]
-		if !ok {
-			if optionalTraitProperty(name) { // don't inherit optional property if not exist
-				continue
-			}
-			qp = NamedParameter{Name: name}
-		}
-		parent.Name = name // parent name is not initialized by the parser
-		qp.inherit(parent, dicts)
-		m.QueryParameters[qp.Name] = qp
-	}
-
-}
-
-// inheritProtocols inherit method's protocols from parent protocols
-// parent protocols could be from resource type or a trait
-func (m *Method) inheritProtocols(parent []string) {
-	for _, p := range parent {
-		m.Protocols = appendStrNotExist(p, m.Protocols)
-	}
-}
-
-// inheritResponses inherit method's responses from parent responses
-// parent responses could be from resource type or a trait
-func (m *Method) inheritResponses(parent map[HTTPCode]Response, dicts map[string]interface{},
-	apiDef *APIDefinition) {
-	if len(m.Responses) == 0 { // allocate if needed
-		m.Responses = map[HTTPCode]Response{}
-	}
-	for code, rParent := range parent {
-		resp, ok := m.Responses[code]
-		if !ok {
-			if optionalTraitProperty(fmt.Sprintf("%v", code)) { // don't inherit optional property if not exist
-				continue
-			}
-			resp = Response{HTTPCode: code}
-		}
-		resp.inherit(rParent, dicts, m.resourceTypeName, apiDef)
-		m.Responses[code] = resp
-	}
-
+	m.Bodies.postProcess(mediaTypes)
 }
 
 // Response property of a method on a resource describes
@@ -251,7 +119,10 @@ func (m *Method) inheritResponses(parent map[HTTPCode]Response, dicts map[string
 // The property values describe the corresponding responses.
 // Each value is a response declaration.
 type Response struct {
-	annotations Annotations `yaml:",inline"`
+	Annotations Annotations `yaml:",inline"`
+
+	// Where the response has been declared.
+	Position Position `yaml:",inline"`
 
 	// HTTP status code of the response
 	HTTPCode HTTPCode
@@ -267,18 +138,32 @@ type Response struct {
 
 	// The body of the response
 	Bodies Bodies `yaml:"body"`
+
+	// The names of the headers of the response, in declaration order.
+	HeaderOrder []HTTPHeader `yaml:"-"`
 }
 
-func (resp *Response) postProcess() {
-	resp.Bodies.postProcess()
+// UnmarshalYAML decodes a response, keeping the order of its headers
+func (resp *Response) UnmarshalYAML(node *yaml.Node) error {
+	type clone Response
+	c := clone{}
+	err := node.Decode(&c)
+	*resp = Response(c)
+	resp.HeaderOrder = headerOrder(node)
+	return err
 }
 
-// inherit from parent response
-func (resp *Response) inherit(parent Response, dicts map[string]interface{}, rtName string,
-	apiDef *APIDefinition) {
-	resp.Description = substituteParams(resp.Description, parent.Description, dicts)
-	resp.Bodies.inherit(parent.Bodies, dicts, rtName, apiDef)
-	resp.Headers = inheritHeaders(resp.Headers, parent.Headers, dicts)
+// headerOrder returns the names of the headers declared by a method or response node, in declaration order
+func headerOrder(node *yaml.Node) []HTTPHeader {
+	var headers []HTTPHeader
+	for _, name := range mappingKeys(mappingValue(node, "headers"), nil) {
+		headers = append(headers, HTTPHeader(name))
+	}
+	return headers
+}
+
+func (resp *Response) postProcess(mediaTypes []string) {
+	resp.Bodies.postProcess(mediaTypes)
 }
 
 // Body is the request/response body
@@ -288,8 +173,9 @@ func (resp *Response) inherit(parent Response, dicts map[string]interface{}, rtN
 // Resources CAN have alternate representations. For example, an API might
 // support both JSON and XML representations.
 type Body struct {
-	mediaType string `yaml:"mediaType"`
-	// TODO: Fill this during the post-processing phase
+	// The media type of the body, e.g. application/json.
+	// Empty for a body declared without media type.
+	MediaType string `yaml:"-"`
 
 	// The structure of a request or response body MAY be further specified
 	// by the schema property under the appropriate media type.
@@ -305,120 +191,164 @@ type Body struct {
 	Description string `yaml:"description"`
 
 	// Example attribute to generate example invocations
-	Example string `yaml:"example"`
+	Example interface{} `yaml:"example"`
+
+	// Examples of the body, by name
+	Examples map[string]interface{} `yaml:"examples"`
+
+	// The examples of Examples, decoded along with their annotations.
+	NamedExamples map[string]Example `yaml:"-"`
 
 	Headers map[HTTPHeader]Header `yaml:"headers"`
+
+	// The type, properties and items of the body
+	BodiesProperty `yaml:",inline"`
+
+	Annotations Annotations `yaml:",inline"`
+
+	// Where the body has been declared.
+	Position Position `yaml:",inline"`
 }
 
-// Bodies is Container of Body types, necessary because of technical reasons.
-//TODO: rework this bit to be more reflective of the actual structure of RAML
-type Bodies struct {
+// UnmarshalYAML decodes a body, which can be declared by the name of its type only
+func (b *Body) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		*b = Body{}
+		if err := node.Decode(&b.Position); err != nil {
+			return err
+		}
+		return node.Decode(&b.Type)
+	}
+	type clone Body
+	c := clone{}
+	err := node.Decode(&c)
+	*b = Body(c)
+	var examplesErr error
+	if b.NamedExamples, examplesErr = decodeNamedExamples(node); examplesErr != nil && err == nil {
+		err = examplesErr
+	}
+	return err
+}
 
-	// Instead of using a simple map[HTTPHeader]Body for the body
-	// property of the Response and Method, we use the Bodies struct. Why?
-	// Because some RAML APIs don't use the MIMEType part, instead relying
-	// on the mediaType property in the APIDefinition.
-	// So, you might see:
-	//
-	// responses:
-	//   200:
-	//     body:
-	//       example: "some_example" : "123"
-	//
-	// and also:
-	//
-	// responses:
-	//   200:
-	//     body:
-	//       application/json:
-	//         example: |
-	//           {
-	//             "some_example" : "123"
-	//           }
-
-	// As in the Body type.
-	Schema string `yaml:"schema"`
+// isEmpty returns true if the body declares nothing
+func (b *Body) isEmpty() bool {
+	return b.Type == nil && b.Schema == "" && b.Description == "" && b.Example == nil && len(b.Examples) == 0 &&
+		len(b.Headers) == 0 && len(b.Properties) == 0 && b.Items == nil && len(b.Annotations.AnnotationNames) == 0
+}
 
-	// As in the Body type.
-	Description string `yaml:"description"`
+func (b *Body) postProcess() {
+	b.BodiesProperty.postProcess()
+}
 
-	// As in the Body type.
-	//TODO: fix example unmarshalling: https://github.com/raml-org/raml-spec/blob/master/versions/raml-10/raml-10.md/#defining-examples-in-raml
-	Example string `yaml:"-"`
+// Bodies holds the body of a method or response, declared for each of its media types.
+// A body can also be declared without media type, relying on the default
+// media types declared by the mediaType property of the API:
+//
+// responses:
+//   200:
+//     body:
+//       type: User
+//
+// and also:
+//
+// responses:
+//   200:
+//     body:
+//       application/json:
+//         type: User
+//       application/xml:
+//         type: User
+//
+// Once post processed, a body declared without media type is declared for
+// each of the default media types of the API.
+type Bodies struct {
+	// The body declared without media type.
+	// It's only kept when the API doesn't declare any default media type.
+	Body `yaml:",inline"`
 
 	// Resources CAN have alternate representations. For example, an API
 	// might support both JSON and XML representations. This is the map
 	// between MIME-type and the body definition related to it.
-	//TODO: fix yaml unmarshalling here
+	// For APIs without a priori knowledge of the response types for
+	// their responses, "*/*" MAY be used to indicate that responses that do
+	// not matching other defined data types MUST be accepted.
 	ForMIMEType map[string]Body `yaml:"-"`
 
-	// TODO: For APIs without a priori knowledge of the response types for
-	// their responses, "*/*" MAY be used to indicate that responses that do
-	// not matching other defined data types MUST be accepted. Processing
-	// applications MUST match the most descriptive media type first if
-	// "*/*" is used.
-	ApplicationJSON *BodiesProperty `yaml:"application/json"`
+	// The media types of ForMIMEType, in declaration order.
+	MediaTypeOrder []string `yaml:"-"`
+}
 
-	// Request/response body type
-	Type string `yaml:"type"`
+// UnmarshalYAML decodes the body of a method or response, either declared
+// for each of its media types or without media type
+func (b *Bodies) UnmarshalYAML(node *yaml.Node) error {
+	*b = Bodies{}
+	if !hasMediaTypeKeys(node) {
+		return node.Decode(&b.Body)
+	}
+	var errs typeErrors
+	b.ForMIMEType = map[string]Body{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		mediaType, _ := optionalKey(key.Value)
+		if !isMediaType(mediaType) {
+			errs = append(errs, fmt.Sprintf("line %d: %s can't be declared along with the media types of a body",
+				key.Line, key.Value))
+			continue
+		}
+		var body Body
+		if err := errs.add(node.Content[i+1].Decode(&body)); err != nil {
+			return err
+		}
+		body.MediaType = mediaType
+		b.ForMIMEType[mediaType] = body
+		b.MediaTypeOrder = append(b.MediaTypeOrder, mediaType)
+	}
+	return errs.err()
 }
 
 // IsEmpty returns true if the body is empty
 func (b *Bodies) IsEmpty() bool {
-	return b.Type == "" && b.ApplicationJSON == nil
+	return b.Type == nil && len(b.ForMIMEType) == 0
 }
 
-// inherit inherits bodies properties from a parent bodies
-// parent object could be from trait or response type
-func (b *Bodies) inherit(parent Bodies, dicts map[string]interface{}, rtName string, apiDef *APIDefinition) {
-	b.Schema = substituteParams(b.Schema, parent.Schema, dicts)
-	b.Description = substituteParams(b.Description, parent.Description, dicts)
-	b.Example = substituteParams(b.Example, parent.Example, dicts)
-
-	b.Type = mergeTypeName(substituteParams(b.Type, parent.Type, dicts), rtName, apiDef)
-
-	// request body
-	if parent.ApplicationJSON != nil {
-		if b.ApplicationJSON == nil { // allocate if needed
-			b.ApplicationJSON = &BodiesProperty{Properties: map[string]interface{}{}}
-		} else if b.ApplicationJSON.Properties == nil {
-			b.ApplicationJSON.Properties = map[string]interface{}{}
-		}
-
-		b.ApplicationJSON.Type = substituteParams(b.ApplicationJSON.TypeString(), parent.ApplicationJSON.TypeString(), dicts)
-		// check if type name is in library
-		if typeStr, ok := b.ApplicationJSON.Type.(string); ok {
-			b.ApplicationJSON.Type = mergeTypeName(typeStr, rtName, apiDef)
+// postProcess declares the body declared without media type for each of the default media types
+func (b *Bodies) postProcess(mediaTypes []string) {
+	if len(mediaTypes) > 0 && !b.Body.isEmpty() {
+		if b.ForMIMEType == nil {
+			b.ForMIMEType = map[string]Body{}
 		}
-
-		for k, p := range parent.ApplicationJSON.Properties {
-			if _, ok := b.ApplicationJSON.Properties[k]; !ok {
-
-				// handle optional properties as described in
-				// https://github.com/raml-org/raml-spec/blob/raml-10/versions/raml-10/raml-10.md#optional-properties
-				switch {
-				case strings.HasSuffix(k, `\?`): // if ended with `\?` we make it optional property
-					k = k[:len(k)-2] + "?"
-				case strings.HasSuffix(k, "?"): // if only ended with `?`, we can ignore it
-					continue
-				}
-				k = substituteParams(k, k, dicts)
-				prop := toProperty(k, p)
-				inheritedType := substituteParams(prop.TypeString(), prop.TypeString(), dicts)
-				b.ApplicationJSON.Properties[k] = mergeTypeName(inheritedType, rtName, apiDef)
+		for _, mediaType := range mediaTypes {
+			if _, ok := b.ForMIMEType[mediaType]; ok {
+				continue
 			}
+			body := b.Body
+			body.MediaType = mediaType
+			b.ForMIMEType[mediaType] = body
+			b.MediaTypeOrder = append(b.MediaTypeOrder, mediaType)
 		}
+		b.Body = Body{}
 	}
 
-	// TODO : formimeytype
+	b.Body.postProcess()
+	for mediaType, body := range b.ForMIMEType {
+		body.postProcess()
+		b.ForMIMEType[mediaType] = body
+	}
 }
 
-func (b *Bodies) postProcess() {
-	if b.ApplicationJSON == nil {
-		return
-	}
+// isMediaType checks whether the key of a body is a media type rather than a property of a body
+func isMediaType(key string) bool {
+	return strings.Contains(key, "/")
+}
 
-	b.ApplicationJSON.postProcess()
+// hasMediaTypeKeys checks whether a body node is declared for each of its media types
+func hasMediaTypeKeys(node *yaml.Node) bool {
+	for _, key := range mappingKeys(node, nil) {
+		if name, _ := optionalKey(key); isMediaType(name) {
+			return true
+		}
+	}
+	return false
 }
 
 // BodiesProperty defines a Body's property
@@ -436,6 +366,11 @@ func (bp BodiesProperty) TypeString() string {
 	return interfaceToString(bp.Type)
 }
 
+// TypeExpression returns the syntax tree of the type expression of the body
+func (bp BodiesProperty) TypeExpression() (TypeExpression, error) {
+	return parseTypeFacet(bp.Type)
+}
+
 // GetProperty gets property with given name
 // from a bodies
 func (bp BodiesProperty) GetProperty(name string) Property {
@@ -468,21 +403,23 @@ func (bp *BodiesProperty) normalizeArray() {
 
 	// make sure `type` value = 'array'
 	typeStr, ok := bp.Type.(string)
-	if !ok && typeStr != arrayType {
+	if !ok || typeStr != arrayType {
 		return
 	}
 
 	// check items value
 	switch item := bp.Items.(type) {
 	case string:
-		bp.Type = item + "[]"
-		bp.Items = nil
-	case map[interface{}]interface{}:
-		tip, ok := item["type"].(string)
+		if items, ok := typeExpressionOf(item); ok {
+			bp.Type = (&ArrayType{Items: items}).String()
+			bp.Items = nil
+		}
+	case map[string]interface{}:
+		items, ok := typeExpressionOf(item["type"])
 		if !ok {
 			return
 		}
-		bp.Type = tip + "[]"
+		bp.Type = (&ArrayType{Items: items}).String()
 		delete(item, "type")
 		bp.Items = item
 	}
@@ -0,0 +1,47 @@
+package raml
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBodies(t *testing.T) {
+	apiDef := new(APIDefinition)
+	err := ParseFile("./testdata/bodies.raml", apiDef)
+	Convey("bodies by media type", t, func() {
+		So(err, ShouldBeNil)
+		orders := apiDef.Resources["/orders"]
+
+		Convey("are declared for each media type", func() {
+			bodies := orders.Get.Responses["200"].Bodies
+			So(bodies.MediaTypeOrder, ShouldResemble, []string{"application/json",
+				"application/vnd.shop.order-list+xml", "application/xml", "text/csv", "application/hal+json"})
+			So(bodies.ForMIMEType["application/vnd.shop.order-list+xml"].Type, ShouldEqual, "Order[]")
+			So(bodies.ForMIMEType["application/vnd.shop.order-list+xml"].MediaType, ShouldEqual,
+				"application/vnd.shop.order-list+xml")
+			So(bodies.ForMIMEType["text/csv"].Type, ShouldEqual, "string")
+			So(bodies.ForMIMEType["application/hal+json"].Type, ShouldEqual, "Order[]")
+		})
+
+		Convey("without media type fall back to the default media types", func() {
+			bodies := orders.Post.Bodies
+			So(bodies.Type, ShouldBeNil)
+			So(bodies.MediaTypeOrder, ShouldResemble, []string{"application/json", "application/xml"})
+			So(bodies.ForMIMEType["application/json"].Type, ShouldEqual, "Order")
+			So(bodies.ForMIMEType["application/xml"].Type, ShouldEqual, "Order")
+
+			created := orders.Post.Responses["201"].Bodies.ForMIMEType["application/xml"]
+			So(created.Type, ShouldEqual, "Order")
+			So(created.Example, ShouldResemble, map[string]interface{}{"id": "42"})
+		})
+
+		Convey("are inherited by media type", func() {
+			bodies := orders.Get.Responses["200"].Bodies
+			So(bodies.ForMIMEType["application/json"].Type, ShouldEqual, "Order[]")
+			So(bodies.ForMIMEType["application/json"].Properties, ShouldContainKey, "page")
+			So(bodies.ForMIMEType["application/xml"].Properties, ShouldContainKey, "page")
+			So(bodies.ForMIMEType["application/xml"].Type, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,184 @@
+package raml
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// This file implements the migration of RAML 0.8 documents to RAML 1.0.
+// The document is migrated from its own nodes rather than from the decoded
+// definition, so that whatever it includes is still included by the result:
+//   - schemas become types, a schema being the type of its type
+//   - schemas of bodies become their type, and form parameters the properties of an object type
+//   - named parameters become type declarations, as they do when parsed, see upgradeParameters
+//   - declarations given as sequences of maps become maps
+//   - the OAuth 2.0 authorization grants are given their RAML 1.0 names
+//
+// Whatever can't be migrated cleanly is reported as a warning.
+
+// Migrate converts the RAML 0.8 document an API definition has been parsed
+// from into the equivalent RAML 1.0 document, read again from its file with
+// the loader given in the options.
+// It returns the RAML 1.0 document and the warnings about the constructs
+// which could not be migrated cleanly, to be reviewed.
+func Migrate(d *APIDefinition, opts ...Option) ([]byte, []Diagnostic, error) {
+	if d.RAMLVersion != ramlVersion08 {
+		return nil, nil, fmt.Errorf("%s is not a RAML 0.8 document", d.Filename)
+	}
+	if d.Filename == "" {
+		return nil, nil, fmt.Errorf("the file the API definition has been parsed from is unknown")
+	}
+
+	p := newParser(opts...)
+	workDir, fileName := filepath.Split(d.Filename)
+	location := fileLocation(workDir, fileName)
+	contents, err := p.load(workDir, fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var document yaml.Node
+	if err = yaml.Unmarshal(contents, &document); err != nil {
+		return nil, nil, err
+	}
+	m := migrator{files: nodeFiles{}}
+	m.files.register(&document, location)
+	if len(document.Content) > 0 && document.Content[0].Kind == yaml.MappingNode {
+		m.migrateRoot(document.Content[0])
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&document); err != nil {
+		return nil, m.diagnostics, err
+	}
+	if err = encoder.Close(); err != nil {
+		return nil, m.diagnostics, err
+	}
+
+	// the header of the original document, encoded as the head comment of the
+	// document, is replaced by the RAML 1.0 one
+	migrated := out.String()
+	if strings.HasPrefix(migrated, "#%RAML") {
+		migrated = migrated[strings.Index(migrated, "\n")+1:]
+	}
+	return []byte("#%RAML 1.0\n" + migrated), m.diagnostics, nil
+}
+
+// migrator migrates the nodes of a RAML 0.8 document, recording
+// the constructs which could not be migrated cleanly
+type migrator struct {
+	diagnostics []Diagnostic
+
+	// files of the nodes of the document
+	files nodeFiles
+}
+
+// warnf records a construct which could not be migrated cleanly
+func (m *migrator) warnf(node *yaml.Node, path []string, format string, args ...interface{}) {
+	m.diagnostics = append(m.diagnostics, Diagnostic{
+		Severity: SeverityWarning,
+		Code:     CodeMigration,
+		Message:  fmt.Sprintf(format, args...),
+		Position: m.files.position(node),
+		Path:     path,
+	})
+}
+
+// migrateRoot migrates the root of a document
+func (m *migrator) migrateRoot(root *yaml.Node) {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if !containsString(declarationKeys08, key.Value) {
+			continue
+		}
+		if value.Kind == yaml.SequenceNode {
+			mergeSequence(value)
+		}
+		if key.Value == "schemas" {
+			if mappingIndex(root, "types") >= 0 {
+				m.warnf(key, []string{"schemas"}, "schemas can't become types, which are already declared")
+				continue
+			}
+			key.Value = "types"
+			upgradeSchemas(value, m.files)
+		}
+		if key.Value == "securitySchemes" {
+			upgradeSecuritySchemes(value)
+		}
+	}
+	m.migrate(root, nil)
+}
+
+// migrate migrates a node and its descendants
+func (m *migrator) migrate(node *yaml.Node, path []string) {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			m.migrate(item, appendPath(path, fmt.Sprintf("[%d]", i)))
+		}
+	case yaml.MappingNode:
+		content := node.Content[:0:0]
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			name, _ := optionalKey(key.Value)
+			keyPath := appendPath(path, key.Value)
+			switch {
+			case name == "baseUriParameters" && len(path) > 0:
+				m.warnf(key, keyPath, "base URI parameters can only be declared by the API in RAML 1.0, they have been removed")
+				continue
+			case name == "body":
+				m.migrateBodies(value, keyPath)
+			case name == "queryParameters" || name == "headers":
+				m.migrateParameters(value, false, keyPath)
+			case name == "uriParameters" || name == "baseUriParameters":
+				m.migrateParameters(value, true, keyPath)
+			}
+			if value.Tag == includeTag && isYAMLInclude(value.Value, nil) {
+				m.warnf(value, keyPath, "included file %s is kept as is, it has to be migrated on its own", value.Value)
+			}
+			m.migrate(value, keyPath)
+			content = append(content, key, value)
+		}
+		node.Content = content
+	}
+}
+
+// migrateBodies migrates the body of a method or response,
+// either declared for a single media type or for each of them
+func (m *migrator) migrateBodies(bodies *yaml.Node, path []string) {
+	if bodies.Kind != yaml.MappingNode {
+		return
+	}
+	for _, key := range []string{"schema", "formParameters", "example", "description"} {
+		if mappingIndex(bodies, key) >= 0 {
+			m.migrateBody(bodies, path)
+			return
+		}
+	}
+	for i := 0; i+1 < len(bodies.Content); i += 2 {
+		m.migrateBody(bodies.Content[i+1], appendPath(path, bodies.Content[i].Value))
+	}
+}
+
+// migrateBody migrates the body of a media type
+func (m *migrator) migrateBody(body *yaml.Node, path []string) {
+	upgradeBody(body, m.files, m.upgradeWarning(path))
+}
+
+// migrateParameters rewrites named parameters as type declarations
+func (m *migrator) migrateParameters(params *yaml.Node, uri bool, path []string) {
+	upgradeParameters(params, uri, m.files, m.upgradeWarning(path))
+}
+
+// upgradeWarning records the constructs which could not be upgraded cleanly under path
+func (m *migrator) upgradeWarning(path []string) upgradeWarning {
+	return func(node *yaml.Node, keys []string, format string, args ...interface{}) {
+		m.warnf(node, appendPath(path, keys...), format, args...)
+	}
+}
@@ -0,0 +1,48 @@
+package raml
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrating(t *testing.T) {
+	asserter := assert.New(t)
+
+	apiDefinition := new(APIDefinition)
+	asserter.NoError(ParseFile("./testdata/raml08/legacy.raml", apiDefinition))
+
+	migrated, diagnostics, err := Migrate(apiDefinition)
+	asserter.NoError(err)
+	expected, err := ioutil.ReadFile("./testdata/raml08/legacy_migrated.raml")
+	asserter.NoError(err)
+	asserter.Equal(string(expected), string(migrated))
+
+	// what could not be migrated cleanly is reported
+	asserter.Len(diagnostics, 3)
+	for _, d := range diagnostics {
+		asserter.Equal(CodeMigration, d.Code)
+		asserter.Equal(SeverityWarning, d.Severity)
+	}
+	asserter.Equal([]string{"traits", "searchable"}, diagnostics[0].Path)
+	asserter.Equal([]string{"/events", "baseUriParameters"}, diagnostics[1].Path)
+	asserter.Equal([]string{"/events", "get", "queryParameters", "tag"}, diagnostics[2].Path)
+
+	// the result is a RAML 1.0 document
+	migratedDefinition, err := Parse(context.Background(), bytes.NewReader(migrated), WithBaseDir("./testdata/raml08/"))
+	asserter.NoError(err)
+	asserter.Equal("1.0", migratedDefinition.RAMLVersion)
+	asserter.Equal("object", migratedDefinition.Types["Event"].Type)
+	asserter.Contains(migratedDefinition.Resources["/events"].Get.QueryParameters, "q")
+	asserter.Equal([]string{"authorization_code", "implicit"},
+		migratedDefinition.SecuritySchemes["oauth_2_0"].OAuth2Settings.AuthorizationGrants)
+
+	// only RAML 0.8 documents are migrated
+	apiDefinition = new(APIDefinition)
+	asserter.NoError(ParseFile("./testdata/basic.raml", apiDefinition))
+	_, _, err = Migrate(apiDefinition)
+	asserter.Error(err)
+}
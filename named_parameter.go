@@ -1,5 +1,9 @@
 package raml
 
+import (
+	"gopkg.in/yaml.v3"
+)
+
 // NamedParameter is collection of named parameters
 // The RAML Specification uses collections of named parameters for the
 // following properties: URI parameters, query string parameters, form
@@ -37,10 +41,12 @@ type NamedParameter struct {
 	// TODO: Verify the enum options
 
 	// If the enum attribute is defined, API clients and servers MUST verify
-	// that a parameter's value matches a value in the enum array
-	// Enum parsing is currently disabled because of:
-	// https://github.com/Jumpscale/go-raml/issues/99
-	//Enum []Any `yaml:",flow"`
+	// that a parameter's value matches a value in the enum array.
+	// It's only a list once the parameters of traits and resource types are substituted.
+	Enum interface{} `yaml:"enum"`
+
+	// The format of the value, e.g. int32 for a number or rfc2616 for a datetime
+	Format string `yaml:"format"`
 
 	// The pattern attribute is a regular expression that a parameter of type
 	// string MUST match. Regular expressions MUST follow the regular
@@ -69,6 +75,12 @@ type NamedParameter struct {
 	// documentation generators to generate sample values for the property.
 	Example interface{}
 
+	// Named examples of values of the parameter, which can't be declared along with example.
+	Examples map[string]interface{} `yaml:"examples"`
+
+	// The examples of Examples, decoded along with their annotations.
+	NamedExamples map[string]Example `yaml:"-"`
+
 	// The repeat attribute specifies that the parameter can be repeated,
 	// i.e. the parameter can be used multiple times
 	Repeat *bool // TODO: What does this mean?
@@ -83,64 +95,39 @@ type NamedParameter struct {
 	// its value is not specified
 	Default Any
 
+	Annotations Annotations `yaml:",inline"`
+
+	// Where the parameter has been declared.
+	// Parameters only declared by a trait or resource type are positioned at that declaration.
+	Position Position `yaml:",inline"`
+
 	format Any `ramlFormat:"Named parameters must be mappings. Example: userId: {displayName: 'User ID', description: 'Used to identify the user.', type: 'integer', minimum: 1, example: 5}"`
 }
 
-// check if an element exist in enum field
-/*func (np *NamedParameter) existInEnum(elem Any) bool {
-	for _, e := range np.Enum {
-		if reflect.DeepEqual(e, elem) {
-			return true
-		}
+// UnmarshalYAML supports the `name: type` shorthand declaration of a parameter
+func (np *NamedParameter) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*np = NamedParameter{Type: node.Value, Position: positionOf(node)}
+		return nil
 	}
-	return false
-}
-*/
-
-func (np *NamedParameter) inherit(parent NamedParameter, dicts map[string]interface{}) {
-	np.Name = substituteParams(np.Name, parent.Name, dicts)
-	np.DisplayName = substituteParams(np.DisplayName, parent.DisplayName, dicts)
-	np.Description = substituteParams(np.Description, parent.Description, dicts)
-	np.Type = parent.Type
-
-	/*
-		for _, elem := range parent.Enum {
-			if !np.existInEnum(elem) {
-				np.Enum = append(np.Enum, elem)
-			}
-		}
-	*/
-	np.Pattern = inheritStringPointer(np.Pattern, parent.Pattern, dicts)
-	np.MinLength = inheritIntPointer(np.MinLength, parent.MinLength)
-	np.MaxLength = inheritIntPointer(np.MaxLength, parent.MaxLength)
-	if parent.Maximum != nil {
-		np.Maximum = parent.Maximum
-	}
-	if parent.Minimum != nil {
-		np.Minimum = parent.Minimum
-	}
-	if parent.Repeat != nil {
-		np.Repeat = parent.Repeat
-	}
-	if parent.Required {
-		np.Required = true
-	}
-}
 
-func inheritStringPointer(val, parent *string, dicts map[string]interface{}) *string {
-	if parent == nil {
-		return val
-	}
-	if val == nil {
-		val = new(string)
+	type clone NamedParameter
+	c := clone{}
+	if err := node.Decode(&c); err != nil {
+		return err
 	}
-	*val = substituteParams(*val, *parent, dicts)
-	return val
+	*np = NamedParameter(c)
+	var err error
+	np.NamedExamples, err = decodeNamedExamples(node)
+	return err
 }
 
-func inheritIntPointer(val, parent *int) *int {
-	if parent == nil {
-		return val
+// UnmarshalYAML decodes a header as any other named parameter
+func (h *Header) UnmarshalYAML(node *yaml.Node) error {
+	var np NamedParameter
+	if err := np.UnmarshalYAML(node); err != nil {
+		return err
 	}
-	return parent
+	*h = Header(np)
+	return nil
 }
@@ -0,0 +1,61 @@
+package raml
+
+// DefaultMaxIncludeDepth is the maximum nesting of !include allowed by default.
+const DefaultMaxIncludeDepth = 32
+
+// An Option configures a parse.
+type Option func(*parser)
+
+// WithLoader sets the loader reading the document and all the files it includes or uses.
+func WithLoader(loader Loader) Option {
+	return func(p *parser) {
+		p.loader = loader
+	}
+}
+
+// WithBaseDir sets the directory, or base URL, against which the files
+// included or used by a document read by Parse are resolved.
+func WithBaseDir(dir string) Option {
+	return func(p *parser) {
+		p.baseDir = dir
+	}
+}
+
+// WithFileName names the document read by Parse, in positions and as its Filename.
+func WithFileName(name string) Option {
+	return func(p *parser) {
+		p.fileName = name
+	}
+}
+
+// WithStrict makes the parse fail on warnings as well as on errors.
+func WithStrict(strict bool) Option {
+	return func(p *parser) {
+		p.strict = strict
+	}
+}
+
+// WithMaxIncludeDepth sets the maximum nesting of !include,
+// a deeper include being reported as an error.
+func WithMaxIncludeDepth(depth int) Option {
+	return func(p *parser) {
+		p.maxIncludeDepth = depth
+	}
+}
+
+// CollectDiagnostics makes the parse keep going after an error, instead of
+// stopping at the first one. The returned *Error then holds all the errors found.
+func CollectDiagnostics() Option {
+	return func(p *parser) {
+		p.collect = true
+	}
+}
+
+// WithLibraryCache shares a library cache between parses, so that a library
+// is parsed once for all the documents using it.
+// Only parses reading their files with the same loader should share a cache.
+func WithLibraryCache(cache *LibraryCache) Option {
+	return func(p *parser) {
+		p.libraries = cache
+	}
+}
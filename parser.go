@@ -30,88 +30,364 @@ package raml
 // This file contains all of the RAML parser related code.
 
 import (
-	"bufio"
 	"bytes"
-	"errors"
+	"context"
 	"fmt"
 	"gopkg.in/yaml.v3"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"net/url"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 )
 
-var (
-	includeStringLen = len("!include ")
+const (
+	includeTag = "!include"
 )
 
+// Parse parses the RAML document read from r.
+// Files included or used by the document are resolved against the base
+// directory given with WithBaseDir, the working directory by default.
+// The returned definition holds whatever could be parsed, even when an error is returned.
+func Parse(ctx context.Context, r io.Reader, opts ...Option) (*APIDefinition, error) {
+	p := newParser(opts...)
+	p.ctx = ctx
+
+	apiDef := new(APIDefinition)
+	contents, err := ioutil.ReadAll(r)
+	if err != nil {
+		return apiDef, err
+	}
+	_, err = p.run(func() ([]byte, error) {
+		return p.parseContents(contents, p.baseDir, p.fileName, apiDef)
+	})
+	return apiDef, err
+}
+
 // ParseFile parses an RAML file.
 // Returns a raml.APIDefinition value or an error if
 // something went wrong.
 func ParseFile(filePath string, root Processor) error {
+	return ParseFileWith(filePath, root)
+}
+
+// ParseFileWith parses an RAML file like ParseFile does, configured by the given options.
+func ParseFileWith(filePath string, root Processor, opts ...Option) error {
 	workDir, fileName := filepath.Split(filePath)
-	_, err := ParseReadFile(workDir, fileName, root)
+	p := newParser(opts...)
+	_, err := p.run(func() ([]byte, error) {
+		return p.parseReadFile(workDir, fileName, root)
+	})
 	return err
 }
 
+// ParseFileAll parses an RAML file like ParseFile does, but keeps going
+// after a problem has been found.
+// It returns every diagnostic found in the document and the libraries and
+// files it includes; the error is an *Error holding the ones of error severity.
+func ParseFileAll(filePath string, root Processor, opts ...Option) ([]Diagnostic, error) {
+	workDir, fileName := filepath.Split(filePath)
+	p := newParser(append([]Option{CollectDiagnostics()}, opts...)...)
+	_, err := p.run(func() ([]byte, error) {
+		return p.parseReadFile(workDir, fileName, root)
+	})
+	return p.diagnostics, err
+}
+
 // ParseReadFile parse an .raml file.
 // It returns API definition and the concatenated .raml file.
 func ParseReadFile(workDir, fileName string, root Processor) ([]byte, error) {
-	// Read original file contents into a byte array
-	mainFileBytes, err := readFileOrURL(workDir, fileName)
+	p := newParser()
+	return p.run(func() ([]byte, error) {
+		return p.parseReadFile(workDir, fileName, root)
+	})
+}
+
+// parser holds the state of a single parse, shared by the root document
+// and all the libraries and files it includes.
+type parser struct {
+	ctx context.Context
+
+	// loader reads all the files of the parse
+	loader Loader
+
+	// directory and name of a document read by Parse
+	baseDir  string
+	fileName string
+
+	// collect makes the parser keep going after an error,
+	// instead of stopping at the first one.
+	collect bool
+
+	// strict turns warnings into errors
+	strict bool
+
+	// maximum nesting of !include, and the current one
+	maxIncludeDepth int
+	includeDepth    int
+
+	// locations of the files being loaded, outermost first,
+	// through which circular includes and libraries are detected
+	chain []string
 
+	// all diagnostics reported so far
+	diagnostics []Diagnostic
+
+	// libraries parsed so far, by canonical location
+	libraries *LibraryCache
+
+	// files of the nodes of the documents being parsed
+	files nodeFiles
+}
+
+// newParser creates a parser configured by the given options
+func newParser(opts ...Option) *parser {
+	p := &parser{
+		ctx:             context.Background(),
+		loader:          DefaultLoader(),
+		maxIncludeDepth: DefaultMaxIncludeDepth,
+		files:           nodeFiles{},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.libraries == nil {
+		p.libraries = NewLibraryCache()
+	}
+	return p
+}
+
+// run runs a parse, returning an error if it reported any
+func (p *parser) run(parse func() ([]byte, error)) ([]byte, error) {
+	// the nodes are forgotten along with the parse, whatever keeps them
+	defer func() { p.files = nodeFiles{} }()
+
+	contents, err := parse()
 	if err != nil {
-		return []byte{}, err
+		return contents, err
+	}
+	return contents, p.err()
+}
+
+// postProcessor is implemented by the processors of this package,
+// which post process their document as part of a running parse.
+type postProcessor interface {
+	postProcess(p *parser, workDir, fileName string) error
+}
+
+// report records a diagnostic.
+// It returns an error when the parse has to stop, which only happens for
+// errors when the parser is not collecting diagnostics.
+func (p *parser) report(d Diagnostic) error {
+	if p.strict && d.Severity == SeverityWarning {
+		d.Severity = SeverityError
+	}
+	p.diagnostics = append(p.diagnostics, d)
+	if d.Severity == SeverityError && !p.collect {
+		return &Error{Diagnostics: []Diagnostic{d}}
+	}
+	return nil
+}
+
+// errorf reports an error diagnostic
+func (p *parser) errorf(code string, pos Position, path []string, format string, args ...interface{}) error {
+	return p.report(Diagnostic{
+		Severity: SeverityError,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+		Position: pos,
+		Path:     path,
+	})
+}
+
+// warnf reports a warning diagnostic
+func (p *parser) warnf(code string, pos Position, path []string, format string, args ...interface{}) error {
+	return p.report(Diagnostic{
+		Severity: SeverityWarning,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+		Position: pos,
+		Path:     path,
+	})
+}
+
+// err returns an *Error holding all the diagnostics of error severity, if any.
+func (p *parser) err() error {
+	var errs []Diagnostic
+	for _, d := range p.diagnostics {
+		if d.Severity == SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &Error{Diagnostics: errs}
+}
+
+// cycle returns the cycle closed by loading location, if it is already being loaded.
+func (p *parser) cycle(location string) []string {
+	for i, loading := range p.chain {
+		if loading == location {
+			return append(append([]string{}, p.chain[i:]...), location)
+		}
+	}
+	return nil
+}
+
+// enter records that the file at location is being loaded, until the returned function is called
+func (p *parser) enter(location string) func() {
+	p.chain = append(p.chain, location)
+	return func() {
+		p.chain = p.chain[:len(p.chain)-1]
+	}
+}
+
+// current returns the location of the file being loaded
+func (p *parser) current() string {
+	if len(p.chain) == 0 {
+		return ""
+	}
+	return p.chain[len(p.chain)-1]
+}
+
+// parseLibrary parses the library used by a document under the given path.
+// A library already parsed from the same location is taken from the cache
+// instead of being parsed again.
+func (p *parser) parseLibrary(workDir, fileName string, path []string) (*Library, error) {
+	location := fileLocation(workDir, fileName)
+	if cycle := p.cycle(location); cycle != nil {
+		return nil, p.errorf(CodeCircularLibrary, Position{File: p.current()}, path,
+			"circular library dependency: %s", strings.Join(cycle, " -> "))
+	}
+
+	key := canonicalLocation(location)
+	if lib, ok := p.libraries.get(key); ok {
+		return lib, nil
+	}
+
+	lib := &Library{Filename: location}
+	errs := p.errorCount()
+	if _, err := p.parseReadFile(workDir, fileName, lib); err != nil {
+		return nil, err
+	}
+	// a library with errors is not cached, so that it gets fixed
+	// before being shared with the next parse
+	if p.errorCount() > errs {
+		return lib, nil
+	}
+	return p.libraries.add(key, lib), nil
+}
+
+// errorCount returns the number of errors reported so far
+func (p *parser) errorCount() int {
+	n := 0
+	for _, d := range p.diagnostics {
+		if d.Severity == SeverityError {
+			n++
+		}
+	}
+	return n
+}
+
+// parseReadFile parses an .raml file into root.
+// A file that can't be read or decoded is reported and left out, the returned
+// error is only set when the parse has to stop.
+func (p *parser) parseReadFile(workDir, fileName string, root Processor) ([]byte, error) {
+	// Read original file contents into a byte array
+	mainFileBytes, err := p.load(workDir, fileName)
+	if err != nil {
+		return []byte{}, p.errorf(CodeInvalidFile, Position{File: fileLocation(workDir, fileName)}, nil,
+			"%s", err.Error())
+	}
+	return p.parseContents(mainFileBytes, workDir, fileName, root)
+}
+
+// parseContents parses the contents of an .raml file into root.
+func (p *parser) parseContents(mainFileBytes []byte, workDir, fileName string, root Processor) ([]byte, error) {
+	var location string
+	if fileName != "" {
+		location = fileLocation(workDir, fileName)
+		defer p.enter(location)()
 	}
 
 	// Get the contents of the main file
 	mainFileBuffer := bytes.NewBuffer(mainFileBytes)
 
-	// Verify the YAML version
-	var ramlVersion string
+	// Verify the RAML version and the kind of document
 	firstLine, err := mainFileBuffer.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return []byte{}, p.errorf(CodeInvalidFile, Position{File: location}, nil,
+			"problem reading RAML file (Error: %s)", err.Error())
+	}
+	version, kind, err := parseHeader(firstLine)
 	if err != nil {
-		return []byte{}, fmt.Errorf("problem reading RAML file (Error: %s)", err.Error())
+		return []byte{}, p.errorf(CodeInvalidHeader, Position{File: location, Line: 1, Column: 1}, nil,
+			"%s", err.Error())
+	}
+	if hr, ok := root.(headerReceiver); ok {
+		if err = hr.receiveHeader(version, kind); err != nil {
+			return []byte{}, p.errorf(CodeFragmentMismatch, Position{File: location, Line: 1, Column: 1}, nil,
+				"%s", err.Error())
+		}
 	}
 
-	// We read some data...
-	if len(firstLine) >= 10 {
-		ramlVersion = firstLine[:10]
+	// Build the node tree of the document
+	var document yaml.Node
+	if err = yaml.Unmarshal(mainFileBytes, &document); err != nil {
+		return []byte{}, p.report(convertYAMLError(location, err.Error()))
 	}
-	if ramlVersion != "#%RAML 1.0" {
-		return []byte{}, errors.New("input file is not a RAML 1.0 file. Make  sure the file starts with #%RAML 1.0")
+	p.files.register(&document, location)
+
+	// Pre-process the document, following !include tags
+	// relative to the directory of the document
+	if err = p.preProcess(&document, fileDir(workDir, fileName), nil); err != nil {
+		return []byte{}, err
 	}
 
-	// Pre-process the original file, following !include directive
-	preprocessedContentsBytes, err := preProcess(mainFileBuffer, workDir)
-	if err != nil {
-		return []byte{}, fmt.Errorf("error preprocessing RAML file (Error: %s)", err.Error())
+	// A RAML 0.8 document is rewritten into the RAML 1.0 one it's equivalent to
+	if version == ramlVersion08 {
+		upgradeDocument(&document, p.files)
 	}
 
-	// Unmarshal into an APIDefinition value
-	err = yaml.Unmarshal(preprocessedContentsBytes, root)
+	// An overlay or extension is decoded once merged into the document it extends
+	if isExtensionKind(kind) {
+		if err = p.extend(&document, kind, workDir, fileName); err != nil {
+			return []byte{}, err
+		}
+		relocateUses(&document, fileDir(workDir, fileName), p.files)
+	}
 
-	// Any errors?
-	if err != nil {
+	// An empty document (e.g. a library holding only its header) has nothing to decode
+	if document.Kind == 0 {
+		return []byte{}, p.postProcess(root, workDir, fileName)
+	}
 
-		// Create a RAML error value
-		ramlError := new(Error)
+	// Unmarshal into an APIDefinition value
+	if err = p.files.decode(&document, root); err != nil {
+		yamlErrors, ok := err.(*yaml.TypeError)
+		if !ok {
+			// Decoding stopped, there is nothing to post process
+			return []byte{}, p.errorf(CodeYAML, Position{File: location}, nil, "%s", err.Error())
+		}
 
-		// Copy the YAML errors into it..
-		if yamlErrors, ok := err.(*yaml.TypeError); ok {
-			populateRAMLError(ramlError, yamlErrors)
-		} else {
-			// Or just any other error, though this shouldn't happen.
-			ramlError.Errors = append(ramlError.Errors, err.Error())
+		// Type errors don't stop the decoding, the rest of the document can be processed
+		for _, yamlError := range yamlErrors.Errors {
+			if err = p.report(convertYAMLError(p.yamlErrorFile(&document, location, yamlError), yamlError)); err != nil {
+				return []byte{}, err
+			}
 		}
+	}
 
-		return []byte{}, ramlError
+	preprocessedContentsBytes, err := yaml.Marshal(&document)
+	if err != nil {
+		return []byte{}, fmt.Errorf("error serializing preprocessed RAML file (Error: %s)", err.Error())
 	}
 
-	if err = root.PostProcess(workDir, fileName); err != nil {
+	if err = p.postProcess(root, workDir, fileName); err != nil {
 		return preprocessedContentsBytes, err
 	}
 
@@ -119,41 +395,76 @@ func ParseReadFile(workDir, fileName string, root Processor) ([]byte, error) {
 	return preprocessedContentsBytes, nil
 }
 
-// read raml file/url
-func readFileOrURL(workingDir, fileName string) ([]byte, error) {
-	// read from URL if it is an URL, otherwise read from local file.
-	if u := strings.Join([]string{workingDir, fileName}, ""); isURL(u) {
-		return readURL(u)
+// yamlErrorFile returns the file of the node a type error has been reported for,
+// which is either the document read from location or one of the files it includes.
+// The node is told by the line and, when given, the value of the error.
+func (p *parser) yamlErrorFile(document *yaml.Node, location, yamlError string) string {
+	m := yamlLineRegexp.FindStringSubmatch(yamlError)
+	if m == nil {
+		return location
+	}
+	line, _ := strconv.Atoi(m[1])
+	var value string
+	if m := yamlUnmarshalRegexp.FindStringSubmatch(yamlError); m != nil {
+		value = m[3]
 	}
-	return readFileContents(workingDir, fileName)
-}
 
-func readURL(address string) ([]byte, error) {
-	resp, err := http.Get(address)
-	if err != nil {
-		return nil, err
+	files := map[string]bool{}
+	var file string
+	walkNodes(document, func(n *yaml.Node) {
+		if n.Line != line || p.files[n] == "" {
+			return
+		}
+		if value != "" && n.Value != value &&
+			!(strings.HasSuffix(value, "...") && strings.HasPrefix(n.Value, strings.TrimSuffix(value, "..."))) {
+			return
+		}
+		if file == "" {
+			file = p.files[n]
+		}
+		files[p.files[n]] = true
+	})
+	// without value, the nodes of the line have to be read from a single file
+	if file == "" || (value == "" && len(files) > 1) {
+		return location
 	}
-	defer resp.Body.Close()
-	return ioutil.ReadAll(resp.Body)
+	return file
 }
 
-// Reads the contents of a file, returns a bytes buffer
-func readFileContents(workingDirectory string, fileName string) ([]byte, error) {
-
-	filePath := filepath.Join(workingDirectory, fileName)
+// postProcess post processes a decoded document as part of this parse
+func (p *parser) postProcess(root Processor, workDir, fileName string) error {
+	if pp, ok := root.(postProcessor); ok {
+		return pp.postProcess(p, workDir, fileName)
+	}
+	if err := root.PostProcess(workDir, fileName); err != nil {
+		return p.errorf(CodePostProcess, Position{File: fileLocation(workDir, fileName)}, nil, "%s", err.Error())
+	}
+	return nil
+}
 
+// load reads a raml file/url with the loader of the parse
+func (p *parser) load(workingDir, fileName string) ([]byte, error) {
 	if fileName == "" {
-		return nil, fmt.Errorf("file name cannot be nil: %s", filePath)
+		return nil, fmt.Errorf("file name cannot be nil: %s", workingDir)
 	}
+	return p.loader.Load(p.ctx, fileLocation(workingDir, fileName))
+}
 
-	// Read the file
-	fileContentsArray, err := ioutil.ReadFile(filePath)
-	if err != nil {
-		return nil,
-			fmt.Errorf("could not read file %s (Error: %s)", filePath, err.Error())
+// fileLocation returns the location of a raml file/url, as given to loaders
+// and reported in positions
+func fileLocation(workingDir, fileName string) string {
+	if isURL(fileName) {
+		return fileName
 	}
-
-	return fileContentsArray, nil
+	if isURL(workingDir) {
+		if base, err := url.Parse(workingDir); err == nil {
+			if ref, err := url.Parse(fileName); err == nil {
+				return base.ResolveReference(ref).String()
+			}
+		}
+		return strings.Join([]string{workingDir, fileName}, "")
+	}
+	return filepath.Join(workingDir, fileName)
 }
 
 // returns true if the path is an HTTP URL
@@ -167,93 +478,165 @@ func isURL(path string) bool {
 }
 
 // preProcess acts as a preprocessor for a RAML document in YAML format,
-// including files referenced via !include. It returns a pre-processed document.
-func preProcess(originalContents io.Reader, workingDirectory string) ([]byte, error) {
-
-	// NOTE: Since YAML doesn't support !include directives, and since go-yaml
-	// does NOT play nice with !include tags, this has to be done like this.
-	// I am considering modifying go-yaml to add custom handlers for specific
-	// tags, to add support for !include, but for now - this method is
-	// GoodEnough(TM) and since it will only happen once, I am not prematurely
-	// optimizing it.
-
-	var preprocessedContents bytes.Buffer
-
-	// Go over each line, looking for !include tags
-	scanner := bufio.NewScanner(originalContents)
-	var line string
-
-	// Scan the file until we reach EOF or error out
-	for scanner.Scan() {
-		line = scanner.Text()
+// resolving every node tagged with !include in place.
+//
+// Included RAML and YAML files become part of the node tree, so their
+// nodes keep the line numbers of the file they were read from. Any other
+// file (JSON, XSD, markdown...) becomes a string scalar.
+// path holds the keys leading to the node, for diagnostics.
+func (p *parser) preProcess(node *yaml.Node, workingDirectory string, path []string) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for i, child := range node.Content {
+			childPath := path
+			if node.Kind == yaml.SequenceNode {
+				childPath = appendPath(path, fmt.Sprintf("[%d]", i))
+			}
+			if err := p.preProcess(child, workingDirectory, childPath); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := p.preProcess(node.Content[i+1], workingDirectory, appendPath(path, node.Content[i].Value)); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if node.Tag == includeTag {
+			return p.includeNode(node, workingDirectory, path)
+		}
+	}
+	return nil
+}
 
-		// Did we find an !include directive to handle?
-		if idx := strings.Index(line, "!include"); idx != -1 {
+// includeNode replaces an !include node by the contents of the file it references.
+// A file that can't be included is reported and replaced by a null node.
+func (p *parser) includeNode(node *yaml.Node, workingDirectory string, path []string) error {
+	included := strings.TrimSpace(node.Value)
+	location := fileLocation(workingDirectory, included)
+	pos := p.files.position(node)
 
-			included := line[idx+includeStringLen:]
+	if cycle := p.cycle(location); cycle != nil {
+		setNullNode(node)
+		return p.errorf(CodeCircularInclude, pos, path, "circular include: %s", strings.Join(cycle, " -> "))
+	}
 
-			preprocessedContents.Write([]byte(line[:idx]))
+	// Get the included file contents
+	includedContents, err := p.load(workingDirectory, included)
+	if err != nil {
+		setNullNode(node)
+		return p.errorf(CodeInvalidInclude, pos, path, "error including file %s: %s", included, err.Error())
+	}
 
-			// Get the included file contents
-			includedContents, err := readFileOrURL(workingDirectory, included)
-			if err != nil {
-				return nil, fmt.Errorf("Error including file %s:\n    %s",
-					included, err.Error())
-			}
+	// we only parse utf8 content
+	if !utf8.Valid(includedContents) {
+		includedContents = []byte("")
+	}
 
-			// we only parse utf8 content
-			if !utf8.Valid(includedContents) {
-				includedContents = []byte("")
-			}
+	if !isYAMLInclude(included, includedContents) {
+		node.Tag = "!!str"
+		node.Value = string(includedContents)
+		node.Style = yaml.LiteralStyle
+		return nil
+	}
 
-			// add newline to included content
-			prepender := []byte("\n")
+	documents, err := decodeDocuments(includedContents)
+	if err != nil {
+		setNullNode(node)
+		return p.errorf(CodeInvalidInclude, pos, path, "error including file %s: %s", included, err.Error())
+	}
 
-			// if it is in response body, we prepend "|" to make it as string
-			trimmedLine := strings.TrimSpace(line)
-			if strings.HasPrefix(trimmedLine, "type ") || strings.HasPrefix(trimmedLine, "type:") { // in body
-				prepender = []byte("|\n")
-			}
-			includedContents = append(prepender, includedContents...)
+	switch len(documents) {
+	case 0:
+		setNullNode(node)
+		return nil
+	case 1:
+	default:
+		setNullNode(node)
+		return p.errorf(CodeInvalidInclude, pos, path, "included file %s contains %d YAML documents, expected one",
+			included, len(documents))
+	}
 
-			// TODO: Check that you only insert .yaml, .raml, .txt and .md files
-			// In case of .raml or .yaml, remove the comments
-			// In case of other files, Base64 them first.
+	if p.includeDepth >= p.maxIncludeDepth {
+		setNullNode(node)
+		return p.errorf(CodeInvalidInclude, pos, path, "error including file %s: maximum include depth of %d exceeded",
+			included, p.maxIncludeDepth)
+	}
 
-			// TODO: Better, step by step checks .. though probably it'll panic
-			// Write text files in the same indentation as the first line
-			internalScanner := bufio.NewScanner(bytes.NewBuffer(includedContents))
+	// a typed fragment has to be of the kind expected where it's included
+	if bytes.HasPrefix(includedContents, []byte("#%RAML")) {
+		firstLine := strings.SplitN(string(includedContents), "\n", 2)[0]
+		version, kind, err := parseHeader(firstLine)
+		if err != nil {
+			setNullNode(node)
+			return p.errorf(CodeInvalidHeader, pos, path, "error including file %s: %s", included, err.Error())
+		}
+		expected, ok := includedFragmentKind(path)
+		if ok && version == ramlVersion10 && kind != expected {
+			setNullNode(node)
+			return p.errorf(CodeFragmentMismatch, pos, path, "included file %s is %s, expected %s",
+				included, kind.article(), expected.article())
+		}
+	}
 
-			// Indent by this much
-			firstLine := true
-			indentationString := ""
+	*node = *documents[0].Content[0]
+	p.files.register(node, location)
 
-			// Go over each line, write it
-			for internalScanner.Scan() {
-				internalLine := internalScanner.Text()
+	// the includes of the included file are relative to its own directory
+	p.includeDepth++
+	defer func() { p.includeDepth-- }()
+	defer p.enter(location)()
+	return p.preProcess(node, fileDir(workingDirectory, included), path)
+}
 
-				preprocessedContents.WriteString(indentationString)
-				if firstLine {
-					indentationString = strings.Repeat(" ", idx)
-					firstLine = false
-				}
+// fileDir returns the directory, or base URL, against which the files
+// included or used by the file at workingDir/fileName are resolved
+func fileDir(workingDir, fileName string) string {
+	if fileName == "" {
+		return workingDir
+	}
+	location := fileLocation(workingDir, fileName)
+	if isURL(location) {
+		// references are resolved against the URL of the file itself
+		return location
+	}
+	return filepath.Dir(location)
+}
 
-				preprocessedContents.WriteString(internalLine)
-				preprocessedContents.WriteByte('\n')
-			}
+// setNullNode turns a node into a null scalar, keeping its position
+func setNullNode(node *yaml.Node) {
+	*node = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Line: node.Line, Column: node.Column}
+}
 
-		} else {
+// appendPath returns a copy of path with the given keys appended
+func appendPath(path []string, keys ...string) []string {
+	return append(append(make([]string, 0, len(path)+len(keys)), path...), keys...)
+}
 
-			// No, just a simple line.. write it
-			preprocessedContents.WriteString(line)
-			preprocessedContents.WriteByte('\n')
+// decodeDocuments decodes every YAML document found in the given contents
+func decodeDocuments(contents []byte) ([]*yaml.Node, error) {
+	var documents []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	for {
+		document := new(yaml.Node)
+		err := decoder.Decode(document)
+		if err == io.EOF {
+			return documents, nil
 		}
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
 	}
+}
 
-	// Any errors encountered?
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading YAML file: %s", err.Error())
+// returns true if an included file has to be parsed as YAML
+// instead of being included as a string
+func isYAMLInclude(fileName string, contents []byte) bool {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".raml", ".yaml", ".yml":
+		return true
 	}
-	// Return the preprocessed contents
-	return preprocessedContents.Bytes(), nil
+	return bytes.HasPrefix(contents, []byte("#%RAML"))
 }
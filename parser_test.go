@@ -30,7 +30,10 @@ package raml
 // This file contains tests.
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -97,4 +100,82 @@ func TestParsingAnnotations(t *testing.T) {
 	asserter.Len(apiDefinition.SecuritySchemes["oauth_1_0"].DescribedBy.Annotations.AnnotationNames, 1)
 	asserter.Len(apiDefinition.Resources["/users"].Annotations.AnnotationNames, 3)
 	asserter.Len(apiDefinition.Resources["/users"].Get.Annotations.AnnotationNames, 2)
+
+	asserter.Len(apiDefinition.AnnotationTypes, 6)
+	asserter.Equal("nil", apiDefinition.AnnotationTypes["deprecated"].Type.Type)
+	asserter.Equal("string?", apiDefinition.AnnotationTypes["feedbackRequested"].Type.Type)
+	asserter.Len(apiDefinition.AnnotationTypes["clearanceLevel"].Properties, 2)
+}
+
+func TestParsingIncludes(t *testing.T) {
+	asserter := assert.New(t)
+
+	apiDefinition := new(APIDefinition)
+	err := ParseFile("./testdata/includes/api.raml", apiDefinition)
+	asserter.NoError(err)
+
+	// included RAML fragments become structured nodes
+	asserter.Equal("object", apiDefinition.Types["User"].Type)
+	asserter.Len(apiDefinition.Types["User"].Properties, 2)
+
+	// included JSON becomes a string, even inside a flow mapping
+	asserter.Equal("object", apiDefinition.Types["Pet"].Type)
+	asserter.Contains(apiDefinition.Types["Pet"].Properties, "name")
+
+	asserter.Equal("Some *markdown* notes.\n", apiDefinition.Documentation[0].Content)
+}
+
+func TestParsingNestedIncludes(t *testing.T) {
+	asserter := assert.New(t)
+
+	apiDefinition := new(APIDefinition)
+	err := ParseFile("./testdata/includes/nested/api.raml", apiDefinition)
+	asserter.NoError(err)
+
+	// includes of included files are relative to them
+	user := apiDefinition.Types["User"]
+	asserter.Equal("{\"name\": \"alice\"}\n", user.Example)
+	asserter.Equal(filepath.Join("testdata", "includes", "nested", "types", "user.raml"), user.Position.File)
+
+	// and so are the ones of libraries
+	asserter.Contains(apiDefinition.Libraries, "common")
+	id := apiDefinition.Libraries["common"].Types["Id"]
+	asserter.Equal("string", id.Type)
+	asserter.Equal(filepath.Join("testdata", "includes", "nested", "types", "id.raml"), id.Position.File)
+}
+
+func TestParsingIncludeWithMultipleDocuments(t *testing.T) {
+	asserter := assert.New(t)
+
+	err := ParseFile("./testdata/includes/multi_api.raml", new(APIDefinition))
+	asserter.Error(err)
+	asserter.Contains(err.Error(), "contains 2 YAML documents")
+}
+
+func TestParseReader(t *testing.T) {
+	asserter := assert.New(t)
+	ctx := context.Background()
+
+	apiDefinition, err := Parse(ctx, strings.NewReader("#%RAML 1.0\ntitle: From a reader\ntypes:\n  User: !include user.raml\n"),
+		WithBaseDir("./testdata/includes/"), WithFileName("upload.raml"))
+	asserter.NoError(err)
+	asserter.Equal("From a reader", apiDefinition.Title)
+	asserter.Equal("object", apiDefinition.Types["User"].Type)
+	asserter.Equal(filepath.Join("testdata", "includes", "upload.raml"), apiDefinition.Position.File)
+
+	// includes nested deeper than allowed
+	nested := "#%RAML 1.0\ntitle: Nested\ntypes:\n  Outer: !include outer.raml\n"
+	_, err = Parse(ctx, strings.NewReader(nested), WithBaseDir("./testdata/includes/"))
+	asserter.NoError(err)
+	_, err = Parse(ctx, strings.NewReader(nested), WithBaseDir("./testdata/includes/"), WithMaxIncludeDepth(1))
+	asserter.Error(err)
+	asserter.Contains(err.Error(), "maximum include depth of 1 exceeded")
+
+	// warnings only fail strict parses
+	deprecated := "#%RAML 1.0\ntitle: Deprecated\ntypes:\n  User:\n    schema: string\n"
+	_, err = Parse(ctx, strings.NewReader(deprecated))
+	asserter.NoError(err)
+	_, err = Parse(ctx, strings.NewReader(deprecated), WithStrict(true))
+	asserter.Error(err)
+	asserter.Equal(CodeDeprecated, err.(*Error).Diagnostics[0].Code)
 }
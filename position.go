@@ -0,0 +1,240 @@
+package raml
+
+import (
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Position locates a RAML element in the file it was declared in.
+type Position struct {
+	// File the element was read from, as it was resolved by the parser.
+	File string
+
+	// Line and Column of the element, both starting at 1.
+	Line   int
+	Column int
+
+	// the node the position has been decoded from, until the parser
+	// has located it in its file, see nodeFiles.decode
+	node *yaml.Node
+}
+
+// UnmarshalYAML captures the position of the node being decoded.
+// Declaring a Position field inline is enough for a RAML element to know where it comes from.
+func (p *Position) UnmarshalYAML(node *yaml.Node) error {
+	*p = positionOf(node)
+	return nil
+}
+
+// IsValid returns true if the position has been set by the parser
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.File == "" {
+			return "-"
+		}
+		return p.File
+	}
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// positionOf returns the position of a node being decoded,
+// its file being filled in once decoded by nodeFiles.decode
+func positionOf(node *yaml.Node) Position {
+	return Position{Line: node.Line, Column: node.Column, node: node}
+}
+
+// nodeFiles remembers which file each node of the documents being parsed has been read from.
+// yaml.Node has no room for it and the model types only get to see the node while decoding,
+// so the parser registers the nodes of every file it loads, and those it creates while merging
+// them, for the time of the parse.
+type nodeFiles map[*yaml.Node]string
+
+// register records the file of a node and all of its children
+func (f nodeFiles) register(node *yaml.Node, file string) {
+	walkNodes(node, func(n *yaml.Node) {
+		f[n] = file
+	})
+}
+
+// position returns the position of a node in the file it was read from
+func (f nodeFiles) position(node *yaml.Node) Position {
+	return Position{
+		File:   f[node],
+		Line:   node.Line,
+		Column: node.Column,
+	}
+}
+
+// positionOr returns the position of a node,
+// in the given file when the one it was read from is not known
+func (f nodeFiles) positionOr(node *yaml.Node, file string) Position {
+	pos := f.position(node)
+	if pos.File == "" {
+		pos.File = file
+	}
+	return pos
+}
+
+// decode decodes a node into v, as node.Decode does, and fills in the files
+// of the positions decoded along with it
+func (f nodeFiles) decode(node *yaml.Node, v interface{}) error {
+	err := node.Decode(v)
+	f.locate(v)
+	return err
+}
+
+// locate fills in the files of the positions held by v, found through
+// its exported fields, pointers, slices and maps
+func (f nodeFiles) locate(v interface{}) {
+	l := locator{files: f, visited: map[locatorVisit]bool{}, types: map[reflect.Type]bool{}}
+	l.locate(reflect.ValueOf(v))
+}
+
+// locator walks decoded values, filling in the files of their positions
+type locator struct {
+	files   nodeFiles
+	visited map[locatorVisit]bool
+	types   map[reflect.Type]bool // whether values of a type can hold positions
+}
+
+type locatorVisit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+var (
+	positionType = reflect.TypeOf(Position{})
+	nodeType     = reflect.TypeOf(yaml.Node{})
+)
+
+// locate locates the positions held by v, returning true if any of them has been changed.
+// Values which are left untouched are not written to, for the libraries shared
+// between parses to be walked safely.
+func (l locator) locate(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return false
+		}
+		visit := locatorVisit{v.Pointer(), v.Type()}
+		if l.visited[visit] {
+			return false
+		}
+		l.visited[visit] = true
+		return l.locate(v.Elem())
+	case reflect.Interface:
+		// only values behind pointers can be changed
+		if !v.IsNil() && v.Elem().Kind() == reflect.Ptr {
+			return l.locate(v.Elem())
+		}
+	case reflect.Struct:
+		if v.Type() == positionType {
+			return l.locatePosition(v)
+		}
+		changed := false
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if (field.PkgPath != "" && !field.Anonymous) || !l.holdsPositions(field.Type) {
+				continue
+			}
+			changed = l.locate(v.Field(i)) || changed
+		}
+		return changed
+	case reflect.Slice, reflect.Array:
+		if !l.holdsPositions(v.Type().Elem()) {
+			return false
+		}
+		changed := false
+		for i := 0; i < v.Len(); i++ {
+			changed = l.locate(v.Index(i)) || changed
+		}
+		return changed
+	case reflect.Map:
+		elem := v.Type().Elem()
+		if !l.holdsPositions(elem) {
+			return false
+		}
+		changed := false
+		for _, key := range v.MapKeys() {
+			value := v.MapIndex(key)
+			if elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface {
+				changed = l.locate(value) || changed
+				continue
+			}
+			// map values can't be changed in place
+			c := reflect.New(elem).Elem()
+			c.Set(value)
+			if l.locate(c) {
+				v.SetMapIndex(key, c)
+				changed = true
+			}
+		}
+		return changed
+	}
+	return false
+}
+
+// locatePosition fills in the file of a position, from the node it has been decoded from
+func (l locator) locatePosition(v reflect.Value) bool {
+	if !v.CanAddr() || !v.CanSet() {
+		return false
+	}
+	pos := v.Addr().Interface().(*Position)
+	if pos.node == nil {
+		return false
+	}
+	if pos.File == "" {
+		pos.File = l.files[pos.node]
+	}
+	pos.node = nil
+	return true
+}
+
+// holdsPositions checks whether values of a type can hold positions
+func (l locator) holdsPositions(t reflect.Type) bool {
+	if holds, ok := l.types[t]; ok {
+		return holds
+	}
+	l.types[t] = true // while being checked, for recursive types
+	holds := false
+	switch t.Kind() {
+	case reflect.Interface:
+		holds = true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		holds = l.holdsPositions(t.Elem())
+	case reflect.Struct:
+		if t == positionType {
+			holds = true
+			break
+		}
+		if t == nodeType {
+			break
+		}
+		for i := 0; i < t.NumField() && !holds; i++ {
+			field := t.Field(i)
+			holds = (field.PkgPath == "" || field.Anonymous) && l.holdsPositions(field.Type)
+		}
+	}
+	l.types[t] = holds
+	return holds
+}
+
+// walkNodes calls fn for the node and all of its descendants
+func walkNodes(node *yaml.Node, fn func(*yaml.Node)) {
+	if node == nil {
+		return
+	}
+	fn(node)
+	for _, child := range node.Content {
+		walkNodes(child, fn)
+	}
+}
@@ -0,0 +1,58 @@
+package raml
+
+import (
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPositions(t *testing.T) {
+	Convey("positions of parsed elements", t, func() {
+		Convey("across included files", func() {
+			apiDef := new(APIDefinition)
+			So(ParseFile("./testdata/includes/api.raml", apiDef), ShouldBeNil)
+
+			api := filepath.Join("testdata", "includes", "api.raml")
+			So(apiDef.Position, ShouldResemble, Position{File: api, Line: 2, Column: 1})
+			So(apiDef.Types["User"].Position, ShouldResemble,
+				Position{File: filepath.Join("testdata", "includes", "user.raml"), Line: 2, Column: 1})
+			So(apiDef.Resources["/users"].Position, ShouldResemble, Position{File: api, Line: 11, Column: 3})
+			So(apiDef.Resources["/users"].Get.Position, ShouldResemble, Position{File: api, Line: 12, Column: 5})
+		})
+
+		Convey("across inheritance", func() {
+			apiDef := new(APIDefinition)
+			So(ParseFile("./testdata/resource_types.raml", apiDef), ShouldBeNil)
+
+			file := filepath.Join("testdata", "resource_types.raml")
+			So(apiDef.Traits["paged"].Position, ShouldResemble, Position{File: file, Line: 67, Column: 7})
+
+			users := apiDef.Resources["/Users"]
+			So(users.InheritedFrom, ShouldResemble, []Position{{File: file, Line: 10, Column: 7}})
+
+			// declared by the resource itself
+			So(users.Get.Position, ShouldResemble, Position{File: file, Line: 95, Column: 5})
+			So(users.Get.InheritedFrom, ShouldContain, Position{File: file, Line: 67, Column: 7})
+			So(users.Get.InheritedFrom, ShouldContain, Position{File: file, Line: 12, Column: 9})
+
+			// only declared by the resource type
+			So(users.Post.Position, ShouldResemble, Position{File: file, Line: 19, Column: 9})
+			So(users.Post.InheritedFrom, ShouldResemble, []Position{{File: file, Line: 19, Column: 9}})
+
+			numPages := users.Get.QueryParameters["numPages"]
+			So(numPages.Position, ShouldResemble, Position{File: file, Line: 69, Column: 11})
+		})
+
+		Convey("of nodes forgotten along with the parse", func() {
+			p := newParser()
+			for i := 0; i < 3; i++ {
+				_, err := p.run(func() ([]byte, error) {
+					return p.parseReadFile("./testdata", "resource_types.raml", new(APIDefinition))
+				})
+				So(err, ShouldBeNil)
+				So(p.files, ShouldBeEmpty)
+			}
+		})
+	})
+}
@@ -3,6 +3,7 @@ package raml
 import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
 	"strings"
 )
 
@@ -32,6 +33,8 @@ type Property struct {
 	UniqueItems bool
 	Items       Items
 
+	Annotations Annotations `yaml:",inline"`
+
 	_type *Type // pointer to Type of this Property
 }
 
@@ -54,34 +57,31 @@ func toProperty(name string, p interface{}) Property {
 		}
 	}
 	// convert from map of interface to property
-	mapToProperty := func(val map[interface{}]interface{}) Property {
+	mapToProperty := func(val map[string]interface{}) Property {
 		var p Property
 		p.Required = true
 		for k, v := range val {
 			switch k {
 			case "type":
-				if p.Format == nil { // if not nil, we already override it
-					p.Type = v.(string)
-				}
+				p.Type = v
 			case "format":
 				p.Format = new(string)
-				*p.Format = v.(string)
-				p.Type = *p.Format
+				*p.Format = interfaceToString(v)
 			case "required":
-				p.Required = v.(bool)
+				p.Required, _ = v.(bool)
 			case "enum":
 				p.Enum = v
 			case "description":
-				p.Description = v.(string)
+				p.Description = interfaceToString(v)
 			case "minLength":
 				p.MinLength = new(int)
-				*p.MinLength = v.(int)
+				*p.MinLength, _ = v.(int)
 			case "maxLength":
 				p.MaxLength = new(int)
-				*p.MaxLength = v.(int)
+				*p.MaxLength, _ = v.(int)
 			case "pattern":
 				p.Pattern = new(string)
-				*p.Pattern = v.(string)
+				*p.Pattern = interfaceToString(v)
 			case "minimum":
 				p.Minimum = new(float64)
 				*p.Minimum = toFloat64(v)
@@ -93,16 +93,23 @@ func toProperty(name string, p interface{}) Property {
 				*p.MultipleOf = toFloat64(v)
 			case "minItems":
 				p.MinItems = new(int)
-				*p.MinItems = v.(int)
+				*p.MinItems, _ = v.(int)
 			case "maxItems":
 				p.MaxItems = new(int)
-				*p.MaxItems = v.(int)
+				*p.MaxItems, _ = v.(int)
 			case "uniqueItems":
-				p.UniqueItems = v.(bool)
+				p.UniqueItems, _ = v.(bool)
 			case "items":
 				p.Items = newItems(v)
 			case "properties":
 				log.Fatalf("Properties field of '%v' should already be deleted. Seems there are unsupported inline type", name)
+			default:
+				if annotationNameRegexp.MatchString(k) {
+					if p.Annotations.AnnotationNames == nil {
+						p.Annotations.AnnotationNames = map[AnnotationName]interface{}{}
+					}
+					p.Annotations.AnnotationNames[AnnotationName(k)] = v
+				}
 			}
 		}
 		return p
@@ -112,13 +119,13 @@ func toProperty(name string, p interface{}) Property {
 	switch p.(type) {
 	case string:
 		prop.Type = p.(string)
-	case map[interface{}]interface{}:
-		prop = mapToProperty(p.(map[interface{}]interface{}))
+	case map[string]interface{}:
+		prop = mapToProperty(p.(map[string]interface{}))
 	case Property:
 		prop = p.(Property)
 	}
 
-	if prop.Type == "" { // if has no type, we set it as string
+	if prop.Type == nil || prop.Type == "" { // if has no type, we set it as string
 		prop.Type = "string"
 	}
 
@@ -154,25 +161,45 @@ func (p Property) IsEnum() bool {
 	return p.Enum != nil
 }
 
+// TypeExpression returns the syntax tree of the type expression of the property
+func (p Property) TypeExpression() (TypeExpression, error) {
+	return ParseTypeExpression(p.TypeString())
+}
+
 // IsBidimensionalArray returns true if
 // this property is a bidimensional array
 func (p Property) IsBidimensionalArray() bool {
-	return strings.HasSuffix(p.TypeString(), "[][]")
+	items, ok := arrayItemsOf(p.TypeString())
+	if !ok {
+		return false
+	}
+	_, ok = items.(*ArrayType)
+	return ok
 }
 
 // IsArray returns true if it is an array
 func (p Property) IsArray() bool {
-	return p.Type == arrayType || strings.HasSuffix(p.TypeString(), "[]")
+	if p.Type == arrayType {
+		return true
+	}
+	_, ok := arrayItemsOf(p.TypeString())
+	return ok
 }
 
 // IsUnion returns true if a property is a union
 func (p Property) IsUnion() bool {
-	return strings.Index(p.TypeString(), "|") > 0
+	_, ok := unionOf(p.TypeString())
+	return ok
 }
 
 // BidimensionalArrayType returns type of the bidimensional array
 func (p Property) BidimensionalArrayType() string {
-	return strings.TrimSuffix(p.TypeString(), "[][]")
+	if items, ok := arrayItemsOf(p.TypeString()); ok {
+		if array, ok := items.(*ArrayType); ok {
+			return ungroup(array.Items).String()
+		}
+	}
+	return p.TypeString()
 }
 
 // ArrayType returns the type of the array
@@ -180,5 +207,31 @@ func (p Property) ArrayType() string {
 	if p.Type == arrayType {
 		return p.Items.Type
 	}
-	return strings.TrimSuffix(p.TypeString(), "[]")
+	if items, ok := arrayItemsOf(p.TypeString()); ok {
+		return items.String()
+	}
+	return p.TypeString()
+}
+
+// decodePropertyAnnotations decodes the annotations applied to the properties
+// declared by the declaration of a type, by name
+func decodePropertyAnnotations(node *yaml.Node) (map[string]Annotations, error) {
+	props := mappingValue(node, "properties")
+	if props == nil || props.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	annotations := map[string]Annotations{}
+	for i := 0; i+1 < len(props.Content); i += 2 {
+		if props.Content[i+1].Kind != yaml.MappingNode {
+			continue
+		}
+		var a Annotations
+		if err := props.Content[i+1].Decode(&a); err != nil {
+			return nil, err
+		}
+		if len(a.AnnotationNames) > 0 {
+			annotations[props.Content[i].Value] = a
+		}
+	}
+	return annotations, nil
 }
@@ -0,0 +1,292 @@
+package raml
+
+import (
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// This file maps RAML 0.8 documents onto the RAML 1.0 model: the nodes of
+// a RAML 0.8 document are rewritten into their RAML 1.0 equivalent before
+// being decoded, so that both versions are decoded the same way.
+//   - schemas, traits, resource types and security schemes declared as
+//     sequences of maps are declared as maps, schemas becoming types
+//   - the schema of a body becomes its type, and its form parameters the
+//     properties of an object type
+//   - named parameters become type declarations, optional unless stated
+//     otherwise but for URI parameters, see upgradeParameters
+//   - the OAuth 2.0 authorization grants are given their RAML 1.0 names
+//   - the parameters of the base URI which are not declared, such as
+//     {version}, are declared implicitly
+
+// root keys of RAML 0.8 declarations which can be sequences of maps
+var declarationKeys08 = []string{"schemas", "traits", "resourceTypes", "securitySchemes"}
+
+// keys of which values are named parameters
+var parameterKeys08 = []string{"queryParameters", "headers", "uriParameters", "baseUriParameters"}
+
+// uriParamRe matches the parameters of a URI template
+var uriParamRe = regexp.MustCompile(`{([^{}]+)}`)
+
+// upgradeDocument rewrites a RAML 0.8 document into a RAML 1.0 one
+func upgradeDocument(document *yaml.Node, files nodeFiles) {
+	if len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		return
+	}
+	root := document.Content[0]
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if !containsString(declarationKeys08, key.Value) {
+			continue
+		}
+		if value.Kind == yaml.SequenceNode {
+			mergeSequence(value)
+		}
+		if key.Value == "schemas" {
+			key.Value = "types"
+			upgradeSchemas(value, files)
+		}
+		if key.Value == "securitySchemes" {
+			upgradeSecuritySchemes(value)
+		}
+	}
+
+	walkNodes(root, func(n *yaml.Node) {
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			name, _ := optionalKey(n.Content[i].Value)
+			value := n.Content[i+1]
+			switch {
+			case name == "body":
+				upgradeBodies(value, files)
+			case containsString(parameterKeys08, name):
+				upgradeParameters(value, name == "uriParameters" || name == "baseUriParameters", files, nil)
+			}
+		}
+	})
+
+	declareBaseURIParameters(root, files)
+}
+
+// mergeSequence turns a sequence of maps into a single map
+func mergeSequence(node *yaml.Node) {
+	var content []*yaml.Node
+	for _, item := range node.Content {
+		if item.Kind == yaml.MappingNode {
+			content = append(content, item.Content...)
+		}
+	}
+	node.Kind, node.Tag, node.Style, node.Content = yaml.MappingNode, "!!map", 0, content
+}
+
+// upgradeSchemas declares each schema as the type of a type
+func upgradeSchemas(schemas *yaml.Node, files nodeFiles) {
+	if schemas.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 1; i < len(schemas.Content); i += 2 {
+		if schema := schemas.Content[i]; schema.Kind == yaml.ScalarNode {
+			schemas.Content[i] = files.wrapNode("type", schema)
+		}
+	}
+}
+
+// RAML 1.0 names of the OAuth 2.0 authorization grants of RAML 0.8
+var authorizationGrants08 = map[string]string{
+	"code":        "authorization_code",
+	"token":       "implicit",
+	"owner":       "password",
+	"credentials": "client_credentials",
+}
+
+// upgradeSecuritySchemes renames the authorization grants of OAuth 2.0 schemes
+func upgradeSecuritySchemes(schemes *yaml.Node) {
+	if schemes.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 1; i < len(schemes.Content); i += 2 {
+		grants := mappingValue(mappingValue(schemes.Content[i], "settings"), "authorizationGrants")
+		if grants == nil || grants.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, grant := range grants.Content {
+			if name, ok := authorizationGrants08[grant.Value]; ok {
+				grant.Value = name
+			}
+		}
+	}
+}
+
+// upgradeBodies rewrites the body of a method or response,
+// either declared for a single media type or for each of them
+func upgradeBodies(bodies *yaml.Node, files nodeFiles) {
+	if bodies.Kind != yaml.MappingNode {
+		return
+	}
+	for _, key := range []string{"schema", "formParameters", "example", "description"} {
+		if mappingIndex(bodies, key) >= 0 {
+			upgradeBody(bodies, files, nil)
+			return
+		}
+	}
+	for i := 1; i < len(bodies.Content); i += 2 {
+		upgradeBody(bodies.Content[i], files, nil)
+	}
+}
+
+// upgradeBody rewrites the body of a media type
+func upgradeBody(body *yaml.Node, files nodeFiles, warnf upgradeWarning) {
+	if body.Kind != yaml.MappingNode {
+		return
+	}
+	if i := mappingIndex(body, "schema"); i >= 0 && mappingIndex(body, "type") < 0 {
+		body.Content[i].Value = "type"
+	}
+	if i := mappingIndex(body, "formParameters"); i >= 0 {
+		params := body.Content[i+1]
+		upgradeParameters(params, false, files, func(node *yaml.Node, keys []string, format string, args ...interface{}) {
+			warnf.report(node, appendPath([]string{"formParameters"}, keys...), format, args...)
+		})
+		body.Content[i].Value = "properties"
+		if mappingIndex(body, "type") < 0 {
+			body.Content = append([]*yaml.Node{files.scalarNode("type", body), files.scalarNode("object", body)}, body.Content...)
+		}
+	}
+}
+
+// upgradeWarning reports a construct which can't be upgraded cleanly,
+// declared under the given keys of the node being upgraded
+type upgradeWarning func(node *yaml.Node, keys []string, format string, args ...interface{})
+
+// report reports a construct which can't be upgraded cleanly, if warnings are reported at all
+func (warnf upgradeWarning) report(node *yaml.Node, keys []string, format string, args ...interface{}) {
+	if warnf != nil {
+		warnf(node, keys, format, args...)
+	}
+}
+
+// upgradeParameters rewrites named parameters into type declarations:
+//   - only the first of multiple types is kept
+//   - the required facet is declared, URI parameters being required unless stated otherwise
+//     and the other ones optional
+//   - dates become datetimes in the format of RFC 2616
+//   - parameters which can be repeated become arrays of their type, the facets of which
+//     apply to the array rather than to its items
+func upgradeParameters(params *yaml.Node, uri bool, files nodeFiles, warnf upgradeWarning) {
+	if params.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(params.Content); i += 2 {
+		name, param := params.Content[i].Value, params.Content[i+1]
+		if param.Kind == yaml.SequenceNode && len(param.Content) > 0 {
+			warnf.report(param, []string{name}, "named parameter %s has multiple types, only the first one has been kept", name)
+			param = param.Content[0]
+			params.Content[i+1] = param
+		}
+		toMappingNode(param)
+		if param.Kind != yaml.MappingNode {
+			continue
+		}
+
+		if mappingIndex(param, "required") < 0 {
+			required := "false"
+			if uri {
+				required = "true"
+			}
+			param.Content = append(param.Content, files.scalarNode("required", param), files.scalarNode(required, param))
+		}
+		if tip := mappingValue(param, "type"); tip != nil && tip.Value == "date" {
+			tip.Value = "datetime"
+			if mappingIndex(param, "format") < 0 {
+				param.Content = append(param.Content, files.scalarNode("format", tip), files.scalarNode("rfc2616", tip))
+			}
+		}
+		if j := mappingIndex(param, "repeat"); j >= 0 {
+			repeat := param.Content[j+1]
+			param.Content = append(param.Content[:j], param.Content[j+2:]...)
+			if repeat.Value == "true" {
+				upgradeRepeat(param, files)
+				warnf.report(param, []string{name},
+					"repeated parameter %s has become an array, its facets apply to the array rather than to its items", name)
+			}
+		}
+	}
+}
+
+// upgradeRepeat turns a parameter which can be repeated into an array of its type,
+// its example becoming an example of the array
+func upgradeRepeat(param *yaml.Node, files nodeFiles) {
+	tip := mappingValue(param, "type")
+	if tip == nil {
+		tip = files.scalarNode("string", param)
+		param.Content = append(param.Content, files.scalarNode("type", param), tip)
+	}
+	tip.Value += "[]"
+	if i := mappingIndex(param, "example"); i >= 0 && param.Content[i+1].Kind == yaml.ScalarNode {
+		example := param.Content[i+1]
+		items := files.newNode(yaml.SequenceNode, "", example)
+		items.Tag, items.Style, items.Content = "!!seq", yaml.FlowStyle, []*yaml.Node{example}
+		param.Content[i+1] = items
+	}
+}
+
+// declareBaseURIParameters declares the parameters of the base URI
+// which are not, {version} defaulting to the version of the API
+func declareBaseURIParameters(root *yaml.Node, files nodeFiles) {
+	baseURI := mappingValue(root, "baseUri")
+	if baseURI == nil || baseURI.Kind != yaml.ScalarNode {
+		return
+	}
+	matches := uriParamRe.FindAllStringSubmatch(baseURI.Value, -1)
+	if len(matches) == 0 {
+		return
+	}
+
+	params := mappingValue(root, "baseUriParameters")
+	if params == nil {
+		params = files.newNode(yaml.MappingNode, "", baseURI)
+		root.Content = append(root.Content, files.scalarNode("baseUriParameters", baseURI), params)
+	}
+	if params.Kind != yaml.MappingNode {
+		return
+	}
+	for _, m := range matches {
+		name := m[1]
+		if mappingIndex(params, name) >= 0 {
+			continue
+		}
+		param := files.newNode(yaml.MappingNode, "", baseURI)
+		param.Content = append(param.Content,
+			files.scalarNode("type", baseURI), files.scalarNode("string", baseURI),
+			files.scalarNode("required", baseURI), files.scalarNode("true", baseURI))
+		if version := mappingValue(root, "version"); name == "version" && version != nil {
+			param.Content = append(param.Content, files.scalarNode("default", baseURI), files.scalarNode(version.Value, baseURI))
+		}
+		params.Content = append(params.Content, files.scalarNode(name, baseURI), param)
+	}
+}
+
+// newNode returns a new node, positioned at the node it's added to
+func (f nodeFiles) newNode(kind yaml.Kind, value string, at *yaml.Node) *yaml.Node {
+	n := &yaml.Node{Kind: kind, Value: value, Line: at.Line, Column: at.Column}
+	if kind == yaml.MappingNode {
+		n.Tag = "!!map"
+	}
+	f[n] = f[at]
+	return n
+}
+
+// scalarNode returns a new scalar, positioned at the node it's added to
+func (f nodeFiles) scalarNode(value string, at *yaml.Node) *yaml.Node {
+	return f.newNode(yaml.ScalarNode, value, at)
+}
+
+// wrapNode returns a map holding the node under the given key
+func (f nodeFiles) wrapNode(key string, node *yaml.Node) *yaml.Node {
+	m := f.newNode(yaml.MappingNode, "", node)
+	m.Content = append(m.Content, f.scalarNode(key, node), node)
+	return m
+}
@@ -0,0 +1,56 @@
+package raml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsingRAML08(t *testing.T) {
+	asserter := assert.New(t)
+
+	apiDefinition := new(APIDefinition)
+	err := ParseFile("./testdata/raml08/api.raml", apiDefinition)
+	asserter.NoError(err)
+	asserter.Equal("0.8", apiDefinition.RAMLVersion)
+	asserter.Equal("Legacy API", apiDefinition.Title)
+
+	// schemas become types
+	asserter.Empty(apiDefinition.Schemas)
+	asserter.Equal("object", apiDefinition.Types["User"].Type)
+	asserter.Contains(apiDefinition.Types["User"].Properties, "name")
+	asserter.Contains(apiDefinition.Types, "Users")
+
+	// declarations are maps
+	asserter.Contains(apiDefinition.Traits, "paged")
+	asserter.Contains(apiDefinition.Traits, "secured")
+	asserter.Contains(apiDefinition.ResourceTypes, "collection")
+	asserter.Equal("Basic Authentication", apiDefinition.SecuritySchemes["basic"].Type)
+
+	// authorization grants are renamed
+	asserter.Equal([]string{"authorization_code", "client_credentials"},
+		apiDefinition.SecuritySchemes["oauth_2_0"].OAuth2Settings.AuthorizationGrants)
+
+	// the parameters of the base URI are declared
+	asserter.Len(apiDefinition.BaseURIParameters, 2)
+	asserter.Equal("v2", apiDefinition.BaseURIParameters["version"].Default)
+	asserter.True(apiDefinition.BaseURIParameters["tenant"].Required)
+
+	// traits and resource types are applied, schemas of bodies becoming types
+	users := apiDefinition.Resources["/users"]
+	asserter.False(users.Get.QueryParameters["page"].Required)
+	asserter.Equal("datetime", users.Get.QueryParameters["since"].Type)
+	asserter.Equal("Users", users.Get.Responses["200"].Bodies.ForMIMEType["application/json"].Type)
+	asserter.True(users.Post.Headers["Authorization"].Required)
+	asserter.Equal("User", users.Nested["/{userId}"].Get.Responses["200"].Bodies.ForMIMEType["application/json"].Type)
+	asserter.True(users.Nested["/{userId}"].URIParameters["userId"].Required)
+
+	// form parameters become the properties of an object
+	asserter.Equal("object", users.Post.Bodies.ForMIMEType["application/x-www-form-urlencoded"].Type)
+	asserter.NotContains(users.Post.Bodies.ForMIMEType, "application/json")
+
+	// RAML 1.0 documents are left as they are
+	apiDefinition = new(APIDefinition)
+	asserter.NoError(ParseFile("./testdata/basic.raml", apiDefinition))
+	asserter.Equal("1.0", apiDefinition.RAMLVersion)
+}
@@ -12,6 +12,15 @@ import (
 type Resource struct {
 	resourceProps `yaml:",inline"`
 	Annotations   Annotations `yaml:",inline"`
+
+	// Where the resource has been declared.
+	Position Position `yaml:",inline"`
+
+	// Where the resource types this resource inherits from have been declared.
+	InheritedFrom []Position `yaml:"-"`
+
+	// the declaration, into which resource types and traits are merged
+	node *yaml.Node
 }
 
 type resourceProps struct {
@@ -55,74 +64,85 @@ type resourceProps struct {
 	// Detailed information about any URI parameters of this resource.
 	URIParameters map[string]NamedParameter `yaml:"uriParameters"`
 
+	// Detailed information about the base URI parameters of this resource,
+	// overriding the ones of the API, as inherited from resource types.
+	BaseURIParameters map[string]NamedParameter `yaml:"baseUriParameters"`
+
 	// A nested resource, which is identified as any property
 	// whose name begins with a slash ("/"), and is therefore treated as a relative URI.
 	Nested map[string]*Resource `yaml:"-"`
 
+	// The URIs of the nested resources, in declaration order.
+	NestedOrder []string `yaml:"-"`
+
 	// A resource defined as a child property of another resource is called a
 	// nested resource, and its property's key is its URI relative to its
 	// parent resource's URI. If this is not nil, then this resource is a
 	// child resource.
 	Parent *Resource
 
-	// all methods of this resource
+	// all methods of this resource, in declaration order,
+	// followed by the ones declared by its resource types only
 	Methods []*Method `yaml:"-"`
 }
 
 func (r *Resource) UnmarshalYAML(node *yaml.Node) error {
 	type clone Resource
 
+	var errs typeErrors
 	c := clone{}
-	if err := node.Decode(&c.resourceProps); err != nil {
+	if err := errs.add(node.Decode(&c.resourceProps)); err != nil {
+		return err
+	}
+	if err := errs.add(node.Decode(&c.Annotations)); err != nil {
 		return err
 	}
-	if err := node.Decode(&c.Annotations); err != nil {
+	if err := errs.add(node.Decode(&c.Position)); err != nil {
+		return err
+	}
+	if err := errs.add(decodeSecuredBy(node, &c.SecuredBy)); err != nil {
 		return err
 	}
 	*r = Resource(c)
+	r.node = node
 	var nested = map[string]*Resource{}
-	for i, childNode := range node.Content {
-		if resourceRegexp.MatchString(childNode.Value) {
-			cc := clone{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if childNode := node.Content[i]; resourceRegexp.MatchString(childNode.Value) {
+			nr := new(Resource)
 			//We fetch the next node, which contains the actual data for the resource
-			err := node.Content[i+1].Decode(&cc)
-			if err != nil {
+			if err := errs.add(node.Content[i+1].Decode(nr)); err != nil {
 				return err
 			}
-			cc.Parent = r
-			nr := Resource(cc)
-			nested[childNode.Value] = &nr
+			nr.Parent = r
+			nested[childNode.Value] = nr
+			r.NestedOrder = append(r.NestedOrder, childNode.Value)
 		}
 	}
 
 	r.Nested = nested
 
-	return nil
+	return errs.err()
 }
 
 // postProcess doing post processing of a resource after being constructed by the parser.
 // - assign all properties that can't be obtained from RAML document
 // - inherit from resource type
 // - inherit from traits
-func (r *Resource) postProcess(uri string, parent *Resource, resourceTypes map[string]ResourceType,
-	traitsMap map[string]Trait, apiDef *APIDefinition) error {
+func (r *Resource) postProcess(p *parser, uri string, parent *Resource, decls declarations) error {
 	r.URI = strings.TrimSpace(uri)
 	r.Parent = parent
 
-	err := r.setMethods(traitsMap, apiDef)
-	if err != nil {
+	// inherit from resource types and traits
+	if err := r.inherit(p, decls); err != nil {
 		return err
 	}
 
-	// inherit from resource types
-	if err = r.inheritResourceType(resourceTypes, apiDef); err != nil {
-		return err
-	}
+	r.setMethods(decls.mediaTypes)
 
 	// process nested/child resources
-	for k := range r.Nested {
+	for _, k := range sortedKeys(r.Nested) {
 		n := r.Nested[k]
-		if err = n.postProcess(k, r, resourceTypes, traitsMap, apiDef); err != nil {
+		if err := n.postProcess(p, k, r, decls); err != nil {
 			return err
 		}
 		r.Nested[k] = n
@@ -130,126 +150,258 @@ func (r *Resource) postProcess(uri string, parent *Resource, resourceTypes map[s
 	return nil
 }
 
-// inherit from a resource type
-func (r *Resource) inheritResourceType(resourceTypes map[string]ResourceType, apiDef *APIDefinition) error {
-	// get resource type object to inherit
-	rt, err := r.getResourceType(resourceTypes)
-	if rt == nil || err != nil {
+// inherit merges the resource types and traits this resource inherits into
+// its declaration, and decodes it again.
+func (r *Resource) inherit(p *parser, decls declarations) error {
+	if r.node == nil || r.node.Kind != yaml.MappingNode {
+		return nil
+	}
+	if err := r.checkTraits(p, decls); err != nil {
 		return err
 	}
+	expandBodies(r.node, decls.mediaTypes, decls.files)
 
-	// initialize dicts
-	dicts := initResourceTypeDicts(r, r.Type.Parameters)
-
-	r.Description = substituteParams(r.Description, rt.Description, dicts)
-
-	// uri parameters
-	if len(r.URIParameters) == 0 {
-		r.URIParameters = map[string]NamedParameter{}
+	// inherit from resource types
+	var chain []resourceTypeLink
+	if r.Type != nil && r.Type.Name != "" {
+		chain = r.resourceTypeChain(decls.resourceTypes)
+		if len(chain) == 0 {
+			if err := p.errorf(CodeUnknownResourceType, r.Type.Position, r.path("type"),
+				"can't find resource type named :%v", r.Type.Name); err != nil {
+				return err
+			}
+		}
 	}
-	for name, up := range rt.URIParameters {
-		p, ok := r.URIParameters[name]
-		if !ok {
-			p = NamedParameter{}
+	var templates []*yaml.Node
+	var inheritedFrom []Position
+	for _, link := range chain {
+		if link.rt.node == nil {
+			continue
 		}
-		p.inherit(up, dicts)
-		r.URIParameters[name] = p
+		templates = append(templates, decls.instantiate(link.rt.node, link.rt.Position.File, link.name, link.dicts,
+			resourceTypeOwnKeys, TargetResource))
+		inheritedFrom = append(inheritedFrom, link.rt.Position)
+	}
+	// the parameters of the URI are implicitly declared, for `uriParameters?` to apply
+	implicit := strings.Contains(r.URI, "{") && mappingIndex(r.node, "uriParameters") < 0
+	if implicit {
+		r.node.Content = append(r.node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "uriParameters"},
+			&yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"})
+	}
+	mergeTemplates(r.node, templates)
+	if i := mappingIndex(r.node, "uriParameters"); implicit && len(r.node.Content[i+1].Content) == 0 {
+		r.node.Content = append(r.node.Content[:i], r.node.Content[i+2:]...)
 	}
 
 	// methods
-	r.inheritMethods(rt, apiDef)
+	methodsInheritedFrom := map[string][]Position{}
+	for _, key := range r.methodKeys(chain) {
+		method := r.methodNode(key, chain, decls.files)
+		if method == nil {
+			continue
+		}
+		methodsInheritedFrom[strings.ToUpper(key)] = r.inheritMethod(method, key, chain, decls)
+	}
 
+	// decode the merged declaration
+	uri, parent := r.URI, r.Parent
+	var merged Resource
+	if err := decls.files.decode(r.node, &merged); err != nil {
+		// errors of the declarations have been reported when first decoding them
+		if _, ok := err.(*yaml.TypeError); !ok {
+			return err
+		}
+	}
+	*r = merged
+	r.URI, r.Parent = uri, parent
+	r.InheritedFrom = inheritedFrom
+	for name, positions := range methodsInheritedFrom {
+		if m := r.MethodByName(name); m != nil {
+			m.InheritedFrom = positions
+		}
+	}
 	return nil
 }
 
-// inherit methods inherits all methods based on it's resource type
-func (r *Resource) inheritMethods(rt *ResourceType, apiDef *APIDefinition) {
-	// inherit all methods from resource type
-	// if it doesn't have the methods, we create it
-	for _, rtm := range rt.methods {
-		m := r.MethodByName(rtm.Name)
-		if m == nil {
-			m = newMethod(rtm.Name)
-			r.assignMethod(m, m.Name)
+// checkTraits reports the unknown traits applied to the resource and its methods
+func (r *Resource) checkTraits(p *parser, decls declarations) error {
+	check := func(is []DefinitionChoice, path []string) error {
+		for _, tDef := range is {
+			if _, ok := decls.traits[tDef.Name]; !ok {
+				if err := p.errorf(CodeUnknownTrait, tDef.Position, path, "invalid traits name:%v", tDef.Name); err != nil {
+					return err
+				}
+			}
 		}
-		m.resourceTypeName = r.Type.Name
-		m.inheritFromResourceType(r, rtm, apiDef)
+		return nil
 	}
-
-	// inherit optional methods if only the resource also has the method
-	for _, rtm := range rt.optionalMethods {
-		m := r.MethodByName(rtm.Name)
-		if m == nil {
-			continue
+	if err := check(r.Is, r.path("is")); err != nil {
+		return err
+	}
+	for _, name := range methodNames {
+		if m := r.MethodByName(name); m != nil {
+			if err := check(m.Is, r.path(strings.ToLower(name), "is")); err != nil {
+				return err
+			}
 		}
-		m.resourceTypeName = r.Type.Name
-		m.inheritFromResourceType(r, rtm, apiDef)
 	}
-
+	return nil
 }
 
-// get resource type from which this resource will inherit
-func (r *Resource) getResourceType(resourceTypes map[string]ResourceType) (*ResourceType, error) {
-	// check if it's specify a resource type to inherit
-	if r.Type == nil || r.Type.Name == "" {
-		return nil, nil
+// methodKeys returns the keys of the methods of the resource, followed by the ones
+// only declared by its resource types, in declaration order
+func (r *Resource) methodKeys(chain []resourceTypeLink) []string {
+	keys := mappingKeys(r.node, isMethodName)
+	for _, link := range chain {
+		for _, key := range mappingKeys(link.rt.node, isMethodName) {
+			if !containsString(keys, key) {
+				keys = append(keys, key)
+			}
+		}
 	}
+	return keys
+}
 
-	// get resource type from array of resource type map
-	for k, rt := range resourceTypes {
-		if k == r.Type.Name {
-			return &rt, nil
+// methodNode returns the node of a method of the resource, creating it when only
+// declared by one of its resource types.
+// It returns nil when the resource doesn't have the method.
+func (r *Resource) methodNode(key string, chain []resourceTypeLink, files nodeFiles) *yaml.Node {
+	if method := mappingValue(r.node, key); method != nil {
+		toMappingNode(method)
+		return method
+	}
+	for _, link := range chain {
+		rtm := mappingValue(link.rt.node, key)
+		if rtm == nil {
+			continue
 		}
+		// a method only declared by a resource type is positioned there
+		method := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: rtm.Line, Column: rtm.Column}
+		files[method] = files.positionOr(rtm, link.rt.Position.File).File
+		r.node.Content = append(r.node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, method)
+		return method
 	}
-	return nil, fmt.Errorf("can't find resource type named :%v", r.Type.Name)
+	return nil
 }
 
-// set methods set all methods name
-// and add it to Methods slice
-func (r *Resource) setMethods(traitsMap map[string]Trait, apiDef *APIDefinition) (err error) {
-	if r.Get != nil {
-		err = r.Get.postProcess(r, "GET", traitsMap, apiDef)
-		if err != nil {
-			return err
+// inheritMethod merges the traits and resource type methods a method
+// inherits into its node, in order of precedence, returning where they have been declared.
+func (r *Resource) inheritMethod(method *yaml.Node, key string, chain []resourceTypeLink, decls declarations) []Position {
+	var templates []*yaml.Node
+	var inheritedFrom []Position
+	applyTrait := func(t Trait, params DefinitionParameters, from string, dicts map[string]interface{}) {
+		if t.node == nil {
+			return
 		}
+		traitDicts := initTraitDicts(r, key, substituteParameters(params, dicts))
+		templates = append(templates, decls.instantiate(t.node, t.Position.File, from, traitDicts, traitOwnKeys, TargetMethod))
+		inheritedFrom = append(inheritedFrom, t.Position)
 	}
-	if r.Post != nil {
-		err = r.Post.postProcess(r, "POST", traitsMap, apiDef)
-		if err != nil {
-			return err
+	applyTraits := func(is []DefinitionChoice, from string, dicts map[string]interface{}) {
+		for _, tDef := range is {
+			if t, ok := decls.lookupTrait(from, tDef.Name); ok {
+				applyTrait(t, tDef.Parameters, from, dicts)
+			}
 		}
 	}
-	if r.Put != nil {
-		err = r.Put.postProcess(r, "PUT", traitsMap, apiDef)
-		if err != nil {
-			return err
+
+	// traits of the method, then of the resource
+	applyTraits(traitsOf(method, decls.files), "", nil)
+	applyTraits(r.Is, "", nil)
+
+	// resource types, with the traits of their methods and their own
+	for _, link := range chain {
+		rtm := mappingValue(link.rt.node, key)
+		if rtm == nil {
+			rtm = mappingValue(link.rt.node, key+"?")
 		}
-	}
-	if r.Patch != nil {
-		err = r.Patch.postProcess(r, "PATCH", traitsMap, apiDef)
-		if err != nil {
-			return err
+		if rtm != nil {
+			dicts := initTraitDicts(r, key, link.dicts)
+			templates = append(templates, decls.instantiate(rtm, link.rt.Position.File, link.name, dicts, methodOwnKeys, ""))
+			inheritedFrom = append(inheritedFrom, decls.files.positionOr(rtm, link.rt.Position.File))
+			applyTraits(traitsOf(rtm, decls.files), link.name, link.dicts)
+		}
+		for _, at := range link.rt.traits {
+			applyTrait(at.trait, at.params, link.name, link.dicts)
 		}
 	}
-	if r.Head != nil {
-		err = r.Head.postProcess(r, "HEAD", traitsMap, apiDef)
-		if err != nil {
-			return err
+	mergeTemplates(method, templates)
+	return inheritedFrom
+}
+
+// resourceTypeLink is one of the resource types inherited by a resource,
+// with the values of its parameters
+type resourceTypeLink struct {
+	name  string // name of the resource type in the resource types map
+	rt    ResourceType
+	dicts map[string]interface{}
+}
+
+// resourceTypeChain returns the resource types inherited by this resource:
+// its own resource type first, followed by the ones inherited in turn.
+// The parameters given to a resource type by another one are substituted
+// with the values of the latter.
+// The chain stops at an unknown or circular resource type, those being
+// reported by checkResourceTypes.
+func (r *Resource) resourceTypeChain(resourceTypes map[string]ResourceType) []resourceTypeLink {
+	var chain []resourceTypeLink
+	seen := map[string]bool{}
+	from, def := "", r.Type
+	dicts := initResourceTypeDicts(r, nil)
+	for def != nil && def.Name != "" {
+		name, rt, ok := lookupResourceType(resourceTypes, from, def.Name)
+		if !ok || seen[name] {
+			break
 		}
+		seen[name] = true
+		dicts = initResourceTypeDicts(r, substituteParameters(def.Parameters, dicts))
+		chain = append(chain, resourceTypeLink{name: name, rt: rt, dicts: dicts})
+		from, def = name, rt.Type
 	}
-	if r.Delete != nil {
-		err = r.Delete.postProcess(r, "DELETE", traitsMap, apiDef)
-		if err != nil {
-			return err
+	return chain
+}
+
+// set methods set all methods name
+// and add it to Methods slice
+func (r *Resource) setMethods(mediaTypes []string) {
+	r.Methods = nil
+	for _, name := range r.methodOrder() {
+		if m := r.MethodByName(name); m != nil {
+			m.postProcess(r, name, mediaTypes)
 		}
 	}
-	if r.Options != nil {
-		err = r.Options.postProcess(r, "OPTIONS", traitsMap, apiDef)
-		if err != nil {
-			return err
+}
+
+// methodOrder returns the names of the methods the resource can have,
+// the ones it declares first, in declaration order
+func (r *Resource) methodOrder() []string {
+	var names []string
+	for _, key := range mappingKeys(r.node, isMethodName) {
+		names = append(names, strings.ToUpper(key))
+	}
+	for _, name := range methodNames {
+		if !containsString(names, name) {
+			names = append(names, name)
 		}
 	}
-	return nil
+	return names
+}
+
+// path returns the path of this resource in the document, followed by the given keys
+func (r *Resource) path(keys ...string) []string {
+	var uris []string
+	for res := r; res != nil; res = res.Parent {
+		uris = append([]string{res.URI}, uris...)
+	}
+	return append(uris, keys...)
+}
+
+// names of the methods a resource can have
+var methodNames = []string{"GET", "POST", "PUT", "PATCH", "HEAD", "DELETE", "OPTIONS"}
+
+// isMethodName checks if a key of a resource is the name of a method
+func isMethodName(key string) bool {
+	return key == strings.ToLower(key) && containsString(methodNames, strings.ToUpper(key))
 }
 
 // MethodByName return resource's method by it's name
@@ -274,39 +426,9 @@ func (r *Resource) MethodByName(name string) *Method {
 	}
 }
 
-func (r *Resource) assignMethod(m *Method, name string) {
-	switch name {
-	case "GET":
-		r.Get = m
-	case "POST":
-		r.Post = m
-	case "PUT":
-		r.Put = m
-	case "PATCH":
-		r.Patch = m
-	case "HEAD":
-		r.Head = m
-	case "DELETE":
-		r.Delete = m
-	case "OPTIONS":
-		r.Options = m
-	default:
-		log.Fatalf("assignMethod fatal error, invalid method name:%v", name)
-	}
-}
-
-// substituteParams substitute all params inside double chevron to the correct value
+// substituteString substitutes all params inside double chevron to the correct value,
 // param value will be obtained from dicts map
-func substituteParams(toReplace, words string, dicts map[string]interface{}) string {
-	// non empty scalar node remain unchanged
-	// except it has double chevron bracket
-	if toReplace != "" && (strings.Index(toReplace, "<<") < 0 && strings.Index(toReplace, ">>") < 0) {
-		return toReplace
-	}
-	if words == "" {
-		return toReplace
-	}
-
+func substituteString(words string, dicts map[string]interface{}) string {
 	removeParamBracket := func(param string) string {
 		param = strings.TrimSpace(param)
 		return param[2 : len(param)-2]
@@ -327,6 +449,19 @@ func substituteParams(toReplace, words string, dicts map[string]interface{}) str
 	return words
 }
 
+// substituteParameters returns a copy of the parameters given to a resource
+// type or trait, the params inside their string values being substituted
+func substituteParameters(params DefinitionParameters, dicts map[string]interface{}) DefinitionParameters {
+	substituted := DefinitionParameters{}
+	for name, val := range params {
+		if str, ok := val.(string); ok {
+			val = substituteString(str, dicts)
+		}
+		substituted[name] = val
+	}
+	return substituted
+}
+
 // get value of a resource type param
 // return false if not exists
 func getParamValue(param string, dicts map[string]interface{}) (string, bool) {
@@ -21,12 +21,12 @@ func TestResourceTypeInheritance(t *testing.T) {
 			So(r.Get, ShouldNotBeNil)
 			So(r.Get.Description, ShouldEqual, "requests to get require authentication")
 			So(r.Get.DisplayName, ShouldEqual, "ListAllUsers")
-			So(r.Get.Responses["200"].Bodies.Type, ShouldEqual, "Users")
+			So(r.Get.Responses["200"].Bodies.ForMIMEType["application/json"].Type, ShouldEqual, "Users")
 
 			So(r.Post, ShouldNotBeNil)
 			So(r.Post.Description, ShouldEqual, "Create a new User")
-			So(r.Post.Bodies.ApplicationJSON.Type, ShouldEqual, "User")
-			So(r.Post.Responses["200"].Bodies.Type, ShouldEqual, "User")
+			So(r.Post.Bodies.ForMIMEType["application/json"].Type, ShouldEqual, "User")
+			So(r.Post.Responses["200"].Bodies.ForMIMEType["application/json"].Type, ShouldEqual, "User")
 		})
 
 		Convey("checking queues - optional method", func() {
@@ -45,7 +45,7 @@ func TestResourceTypeInheritance(t *testing.T) {
 
 			So(r.Post, ShouldNotBeNil)
 
-			props := r.Post.Bodies.ApplicationJSON.Properties
+			props := r.Post.Bodies.ForMIMEType["application/json"].Properties
 			So(ToProperty("name", props["name"]).Type, ShouldEqual, "string")
 			So(ToProperty("age", props["age"]).Type, ShouldEqual, "number")
 			So(r.Post.Headers["X-Chargeback"].Required, ShouldBeTrue)
@@ -57,7 +57,7 @@ func TestResourceTypeInheritance(t *testing.T) {
 			// check resourcePathName parsing
 			respCode := HTTPCode("200")
 			So(mem.Get.Responses, ShouldContainKey, respCode)
-			So(mem.Get.Responses[respCode].Bodies.Type, ShouldEqual, "corps")
+			So(mem.Get.Responses[respCode].Bodies.ForMIMEType["application/json"].Type, ShouldEqual, "corps")
 		})
 
 		Convey("books - query parameters", func() {
@@ -98,7 +98,7 @@ func TestResourceTypeInheritance(t *testing.T) {
 			r := apiDef.Resources["/servers"]
 			So(r, ShouldNotBeNil)
 
-			props := r.Post.Bodies.ApplicationJSON.Properties
+			props := r.Post.Bodies.ForMIMEType["application/json"].Properties
 
 			So(props, ShouldContainKey, "name")
 			So(props, ShouldContainKey, "address?")
@@ -115,3 +115,149 @@ func TestResourceTypeInheritance(t *testing.T) {
 		})
 	})
 }
+
+func TestResourceTypeChains(t *testing.T) {
+	Convey("resource type chains", t, func() {
+		apiDef := new(APIDefinition)
+		diagnostics, err := ParseFileAll("./testdata/resource_type_chains.raml", apiDef)
+		So(err, ShouldNotBeNil)
+		So(diagnostics, ShouldHaveLength, 2)
+
+		Convey("circular and unknown resource types are reported once", func() {
+			So(diagnostics[0].Code, ShouldEqual, CodeCircularResourceType)
+			So(diagnostics[0].Message, ShouldEqual, "circular resource type inheritance: loopA -> loopB -> loopA")
+			So(diagnostics[0].Path, ShouldResemble, []string{"resourceTypes", "loopA", "type"})
+			So(diagnostics[1].Code, ShouldEqual, CodeUnknownResourceType)
+			So(diagnostics[1].Path, ShouldResemble, []string{"resourceTypes", "orphan", "type"})
+			So(diagnostics[1].Position.Line, ShouldEqual, 31)
+		})
+
+		Convey("parameters flow through the chain", func() {
+			r := apiDef.Resources["/users"]
+			So(r.Description, ShouldEqual, "The users resource")
+			So(r.InheritedFrom, ShouldHaveLength, 2)
+			So(r.Post.Description, ShouldEqual, "Create a user")
+			So(r.Get, ShouldNotBeNil)
+			So(r.Get.Responses["200"].Description, ShouldEqual, "All the users")
+		})
+
+		Convey("resource type traits apply to all methods", func() {
+			r := apiDef.Resources["/users"]
+			for _, m := range []*Method{r.Get, r.Post, r.Delete} {
+				So(m.Headers, ShouldContainKey, HTTPHeader("Authorization"))
+				So(m.QueryParameters, ShouldContainKey, "size")
+				So(m.QueryParameters["size"].Description, ShouldEqual, "At most 50 users per page")
+			}
+		})
+	})
+}
+
+func TestMergingNodes(t *testing.T) {
+	Convey("merging resource types and traits", t, func() {
+		apiDef := new(APIDefinition)
+		So(ParseFile("./testdata/merge.raml", apiDef), ShouldBeNil)
+
+		r := apiDef.Resources["/items"]
+		So(r.Description, ShouldEqual, "A collection of items")
+		So(r.Annotations.AnnotationNames, ShouldContainKey, AnnotationName("(audited)"))
+
+		Convey("the method keeps its own values", func() {
+			q := r.Get.QueryParameters["q"]
+			So(q.Type, ShouldEqual, "integer")
+			So(q.Description, ShouldEqual, "Search query")
+			So(*q.MinLength, ShouldEqual, 3)
+		})
+
+		Convey("resource type methods take precedence over their traits", func() {
+			So(r.Get.Description, ShouldEqual, "From the resource type")
+			So(r.Get.Annotations.AnnotationNames, ShouldContainKey, AnnotationName("(cached)"))
+		})
+
+		Convey("arrays are merged", func() {
+			So(r.Get.Protocols, ShouldResemble, []string{"HTTP", "HTTPS"})
+		})
+
+		Convey("optional nodes only apply to existing ones", func() {
+			So(r.Get.Headers[HTTPHeader("X-Trace")].Required, ShouldBeTrue)
+			So(r.Get.Headers[HTTPHeader("X-Trace")].Description, ShouldEqual, "Trace id")
+			So(r.Get.QueryParameters, ShouldNotContainKey, "pageSize")
+			So(r.Post, ShouldBeNil)
+		})
+
+		Convey("methods are listed with inherited ones", func() {
+			So(r.Methods, ShouldHaveLength, 1)
+			So(r.Methods[0].Name, ShouldEqual, "GET")
+		})
+	})
+}
+
+func TestOptionalNodes(t *testing.T) {
+	Convey("optional nodes of traits and resource types", t, func() {
+		apiDef := new(APIDefinition)
+		So(ParseFile("./testdata/optional_nodes.raml", apiDef), ShouldBeNil)
+
+		Convey("apply to nodes inherited from other templates", func() {
+			get := apiDef.Resources["/items"].Nested["/{id}"].Get
+			So(get.Responses, ShouldContainKey, HTTPCode("401"))
+			So(get.Responses, ShouldContainKey, HTTPCode("200"))
+
+			get = apiDef.Resources["/pings"].Get
+			So(get.Headers, ShouldContainKey, HTTPHeader("X-Trace"))
+			So(get.Headers[HTTPHeader("Authorization")].Required, ShouldBeTrue)
+		})
+
+		Convey("are left out when the node is missing", func() {
+			get := apiDef.Resources["/items"].Nested["/{id}"].Get
+			So(get.Headers, ShouldNotContainKey, HTTPHeader("Authorization"))
+			So(get.QueryParameters, ShouldNotContainKey, "page")
+			So(get.Bodies.ForMIMEType, ShouldNotContainKey, "application/json")
+
+			So(apiDef.Resources["/pings"].Get.Responses, ShouldNotContainKey, HTTPCode("401"))
+		})
+
+		Convey("uri parameters are implicitly declared by the URI", func() {
+			item := apiDef.Resources["/items"].Nested["/{id}"]
+			So(item.URIParameters, ShouldContainKey, "id")
+			So(item.URIParameters["id"].Description, ShouldEqual, "The id of the item")
+			So(item.BaseURIParameters, ShouldBeEmpty)
+
+			plain := apiDef.Resources["/items"].Nested["/plain"]
+			So(plain.URIParameters, ShouldBeEmpty)
+			So(plain.BaseURIParameters["region"].Type, ShouldEqual, "string")
+			So(plain.BaseURIParameters["region"].Description, ShouldEqual, "Region of the plain")
+		})
+	})
+}
+
+func TestDeclarationOrder(t *testing.T) {
+	Convey("Given an API declaring elements out of alphabetical order", t, func() {
+		apiDef := new(APIDefinition)
+		err := ParseFile("./testdata/order.raml", apiDef)
+		So(err, ShouldBeNil)
+
+		Convey("declarations are kept in order", func() {
+			So(apiDef.ResourceOrder, ShouldResemble, []string{"/zoo", "/aquarium", "/mall"})
+			So(apiDef.TypeOrder, ShouldResemble, []string{"Zebra", "Ant", "Mole"})
+			So(apiDef.TraitOrder, ShouldResemble, []string{"zoned", "anchored"})
+			So(apiDef.ResourceTypeOrder, ShouldResemble, []string{"item", "collection"})
+		})
+
+		Convey("methods are in declaration order, followed by the ones of resource types", func() {
+			zoo := apiDef.Resources["/zoo"]
+			var names []string
+			for _, m := range zoo.Methods {
+				names = append(names, m.Name)
+			}
+			So(names, ShouldResemble, []string{"POST", "GET", "PATCH", "PUT", "DELETE"})
+			So(zoo.NestedOrder, ShouldResemble, []string{"/zebras", "/ants"})
+		})
+
+		Convey("parameters, headers and responses are kept in order", func() {
+			get := apiDef.Resources["/zoo"].Get
+			So(get.QueryParameterOrder, ShouldResemble, []string{"zone", "area"})
+			So(get.HeaderOrder, ShouldResemble, []HTTPHeader{"X-Zulu", "X-Alpha", "Z-Header"})
+			So(get.ResponseOrder, ShouldResemble, []HTTPCode{"404", "200"})
+			So(get.Responses["200"].HeaderOrder, ShouldResemble, []HTTPHeader{"Z-Last", "A-First"})
+		})
+	})
+}
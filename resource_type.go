@@ -2,9 +2,10 @@ package raml
 
 import (
 	"regexp"
+	"sort"
 	"strings"
 
-	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -65,6 +66,10 @@ type ResourceType struct {
 	// Individual methods can override this declaration.
 	Is []DefinitionChoice `yaml:"is"`
 
+	// The resource type this resource type inherits, which may itself inherit another one.
+	// Parameters given to it may refer to the parameters of this resource type.
+	Type *DefinitionChoice `yaml:"type"`
+
 	// In a REST-ful API, methods are operations that are performed on a
 	// resource. A method MUST be one of the HTTP methods defined in the
 	// HTTP version 1.1 specification [RFC2616] and its extension,
@@ -98,89 +103,158 @@ type ResourceType struct {
 	OptionalPatch             *Method                   `yaml:"patch?"`
 	OptionalOptions           *Method                   `yaml:"options?"`
 
-	methods         []*Method // all non-nil methods
-	optionalMethods []*Method // all non-nil optional methods
+	// Annotations applied to the resource type itself, which are not inherited by the resources.
+	Annotations Annotations `yaml:",inline"`
+
+	// Where the resource type has been declared.
+	Position Position `yaml:",inline"`
+
+	methods         []*Method      // all non-nil methods
+	optionalMethods []*Method      // all non-nil optional methods
+	traits          []appliedTrait // traits of Is, applied to all methods of the inheriting resource
+
+	// the declaration, merged into the resources inheriting the resource type
+	node *yaml.Node
+}
+
+// appliedTrait is a trait applied with the given parameters
+type appliedTrait struct {
+	trait  Trait
+	params DefinitionParameters
 }
 
 // postProcess doing post processing of a resource type after being constructed
 // by the .raml parser, some of the works:
 // - assign all properties that can't be obtained from RAML document
-// - inherit from other resource type
-// - apply traits
-func (rt *ResourceType) postProcess(name string, traitsMap map[string]Trait, apiDef *APIDefinition) error {
+// - apply the traits of its methods
+// - resolve the traits applied to the resource type
+// Inheriting from other resource types is done by the inheriting resource,
+// see Resource.resourceTypeChain.
+func (rt *ResourceType) postProcess(p *parser, name string, traitsMap map[string]Trait, mediaTypes []string) error {
 	rt.Name = name
-	err := rt.setMethods(traitsMap, apiDef)
-	if err != nil {
+	if err := rt.applyMethodTraits(p, traitsMap, mediaTypes); err != nil {
 		return err
 	}
+	rt.setMethods()
 	rt.setOptionalMethods()
 
-	// TODO : inherit from other resource type
+	// resource type level traits
+	rt.traits = nil
+	for _, tDef := range rt.Is {
+		t, ok := traitsMap[tDef.Name]
+		if !ok {
+			if err := p.errorf(CodeUnknownTrait, tDef.Position, []string{"resourceTypes", name, "is"},
+				"invalid traits name:%v", tDef.Name); err != nil {
+				return err
+			}
+			continue
+		}
+		rt.traits = append(rt.traits, appliedTrait{trait: t, params: tDef.Parameters})
+	}
+	return nil
+}
+
+// UnmarshalYAML decodes a resource type, keeping its declaration to be merged into resources
+func (rt *ResourceType) UnmarshalYAML(node *yaml.Node) error {
+	type clone ResourceType
+	c := clone{}
+	err := node.Decode(&c)
+	*rt = ResourceType(c)
+	rt.node = node
+	return err
+}
 
-	// TODO : apply traits
+// applyMethodTraits merges the traits applied to the methods of the resource
+// type into them, as the resource type is seen by the API.
+// The declaration is left untouched, the resources inheriting the resource
+// type merging those traits themselves.
+func (rt *ResourceType) applyMethodTraits(p *parser, traitsMap map[string]Trait, mediaTypes []string) error {
+	if rt.node == nil || rt.node.Kind != yaml.MappingNode {
+		return nil
+	}
+	decls := declarations{traits: traitsMap, mediaTypes: mediaTypes, files: p.files}
+	node := p.files.copyNode(rt.node, rt.Position.File)
+	expandBodies(node, mediaTypes, p.files)
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, method := node.Content[i].Value, node.Content[i+1]
+		name, _ := optionalKey(key)
+		if !isMethodName(name) {
+			continue
+		}
+		toMappingNode(method)
+		var templates []*yaml.Node
+		for _, tDef := range traitsOf(method, p.files) {
+			t, ok := traitsMap[tDef.Name]
+			if !ok {
+				if err := p.errorf(CodeUnknownTrait, tDef.Position, []string{"resourceTypes", rt.Name, key, "is"},
+					"invalid traits name:%v", tDef.Name); err != nil {
+					return err
+				}
+				continue
+			}
+			if t.node == nil {
+				continue
+			}
+			templates = append(templates, decls.instantiate(t.node, t.Position.File, "",
+				initTraitDicts(nil, name, tDef.Parameters), traitOwnKeys, TargetMethod))
+		}
+		mergeTemplates(method, templates)
+	}
+
+	var applied ResourceType
+	if err := p.files.decode(node, &applied); err != nil {
+		// errors of the declaration have been reported when first decoding it
+		if _, ok := err.(*yaml.TypeError); !ok {
+			return err
+		}
+	}
+	applied.Name, applied.node = rt.Name, rt.node
+	*rt = applied
 	return nil
 }
 
+// traitsOf returns the traits applied by the `is` property of a method or resource node
+func traitsOf(node *yaml.Node, files nodeFiles) []DefinitionChoice {
+	var is []DefinitionChoice
+	if value := mappingValue(node, "is"); value != nil {
+		// invalid values have been reported when first decoding the node
+		_ = files.decode(value, &is)
+	}
+	return is
+}
+
 // set methods set all methods name
 // and add it to methods slice
-func (rt *ResourceType) setMethods(traitsMap map[string]Trait, apiDef *APIDefinition) (err error) {
+func (rt *ResourceType) setMethods() {
 	if rt.Get != nil {
 		rt.Get.Name = "GET"
-		err = rt.Get.inheritFromTraits(nil, append(rt.Is, rt.Get.Is...), traitsMap, apiDef)
-		if err != nil {
-			return err
-		}
 		rt.methods = append(rt.methods, rt.Get)
 	}
 	if rt.Post != nil {
 		rt.Post.Name = "POST"
-		err = rt.Post.inheritFromTraits(nil, append(rt.Is, rt.Post.Is...), traitsMap, apiDef)
-		if err != nil {
-			return err
-		}
 		rt.methods = append(rt.methods, rt.Post)
 	}
 	if rt.Put != nil {
 		rt.Put.Name = "PUT"
-		err = rt.Put.inheritFromTraits(nil, append(rt.Is, rt.Put.Is...), traitsMap, apiDef)
-		if err != nil {
-			return err
-		}
 		rt.methods = append(rt.methods, rt.Put)
 	}
 	if rt.Patch != nil {
 		rt.Patch.Name = "PATCH"
-		err = rt.Patch.inheritFromTraits(nil, append(rt.Is, rt.Patch.Is...), traitsMap, apiDef)
-		if err != nil {
-			return err
-		}
 		rt.methods = append(rt.methods, rt.Patch)
 	}
 	if rt.Head != nil {
 		rt.Head.Name = "HEAD"
-		err = rt.Head.inheritFromTraits(nil, append(rt.Is, rt.Head.Is...), traitsMap, apiDef)
-		if err != nil {
-			return err
-		}
 		rt.methods = append(rt.methods, rt.Head)
 	}
 	if rt.Delete != nil {
 		rt.Delete.Name = "DELETE"
-		err = rt.Delete.inheritFromTraits(nil, append(rt.Is, rt.Delete.Is...), traitsMap, apiDef)
-		if err != nil {
-			return err
-		}
 		rt.methods = append(rt.methods, rt.Delete)
 	}
 	if rt.Options != nil {
 		rt.Options.Name = "OPTIONS"
-		err = rt.Options.inheritFromTraits(nil, append(rt.Is, rt.Options.Is...), traitsMap, apiDef)
-		if err != nil {
-			return err
-		}
 		rt.methods = append(rt.methods, rt.Options)
 	}
-	return err
 }
 
 // setOptionalMethods set name of all optional methods
@@ -216,42 +290,84 @@ func (rt *ResourceType) setOptionalMethods() {
 	}
 }
 
-func initResourceTypeDicts(r *Resource, dicts map[string]interface{}) map[string]interface{} {
-	if len(dicts) == 0 {
-		dicts = map[string]interface{}{}
-	}
-	if r != nil {
-		dicts["resourcePathName"] = r.resourcePathName()
-		dicts["resourcePath"] = r.FullURI()
+// lookupResourceType returns the resource type named name, as referred to by
+// the resource type named from, along with its name in resourceTypes.
+// Inside a library, a name refers to the resource types of that library first.
+func lookupResourceType(resourceTypes map[string]ResourceType, from, name string) (string, ResourceType, bool) {
+	if i := strings.LastIndex(from, "."); i >= 0 {
+		qualified := from[:i+1] + name
+		if rt, ok := resourceTypes[qualified]; ok {
+			return qualified, rt, true
+		}
 	}
-	return dicts
+	rt, ok := resourceTypes[name]
+	return name, rt, ok
 }
 
-// merge name of type with resource type name.
-func mergeTypeName(name, rtName string, apiDef *APIDefinition) string {
-	if apiDef == nil {
-		log.Warning("passing nil API definition to mergeTypeName")
-		return name
+// checkResourceTypes reports the unknown resource types inherited by
+// resource types, and the circular inheritances between them.
+// Each problem is reported once, where the resource types are declared,
+// rather than for every resource inheriting them.
+func checkResourceTypes(p *parser, resourceTypes map[string]ResourceType) error {
+	names := make([]string, 0, len(resourceTypes))
+	for name := range resourceTypes {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	// check if this resource type is in library
-	splt := strings.Split(rtName, ".")
-	if len(splt) < 2 {
-		// if not in library, we need to do nothing
-		return name
-	}
+	inCycle := map[string]bool{}
+	for _, name := range names {
+		rt := resourceTypes[name]
+		if rt.Type == nil || rt.Type.Name == "" {
+			continue
+		}
+		path := []string{"resourceTypes", rt.Name, "type"}
+		if _, _, ok := lookupResourceType(resourceTypes, name, rt.Type.Name); !ok {
+			if err := p.errorf(CodeUnknownResourceType, rt.Type.Position, path,
+				"can't find resource type named :%v", rt.Type.Name); err != nil {
+				return err
+			}
+			continue
+		}
+		if inCycle[name] {
+			continue
+		}
 
-	// get the library object from API definition root object
-	libName := splt[0]
-	lib, ok := apiDef.Libraries[libName]
-	if !ok {
-		return name
+		// follow the chain until it ends, or comes back to a resource type
+		chain := []string{name}
+		for cur := rt; cur.Type != nil && cur.Type.Name != ""; {
+			next, nextRT, ok := lookupResourceType(resourceTypes, chain[len(chain)-1], cur.Type.Name)
+			if !ok {
+				break
+			}
+			if next == name {
+				for _, n := range chain {
+					inCycle[n] = true
+				}
+				if err := p.errorf(CodeCircularResourceType, rt.Type.Position, path,
+					"circular resource type inheritance: %s", strings.Join(append(chain, name), " -> ")); err != nil {
+					return err
+				}
+				break
+			}
+			if containsString(chain, next) {
+				// a cycle not going through this resource type, reported from one of its members
+				break
+			}
+			chain = append(chain, next)
+			cur = nextRT
+		}
 	}
+	return nil
+}
 
-	// type not exist in the library
-	if _, ok := lib.Types[name]; !ok {
-		return name
+func initResourceTypeDicts(r *Resource, dicts map[string]interface{}) map[string]interface{} {
+	if len(dicts) == 0 {
+		dicts = map[string]interface{}{}
 	}
-
-	return strings.Join([]string{libName, name}, ".")
+	if r != nil {
+		dicts["resourcePathName"] = r.resourcePathName()
+		dicts["resourcePath"] = r.FullURI()
+	}
+	return dicts
 }
@@ -1,5 +1,12 @@
 package raml
 
+import (
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
 // DescribedBy is a description of the following security-related
 // request components determined by the scheme:
 //   the headers, query parameters, or responses
@@ -55,4 +62,159 @@ type SecurityScheme struct {
 
 	// The settings attribute MAY be used to provide security scheme-specific information.
 	Settings map[string]Any `yaml:"settings"`
+
+	// The settings of an OAuth 1.0 scheme, nil for other schemes
+	OAuth1Settings *OAuth1Settings `yaml:"-"`
+
+	// The settings of an OAuth 2.0 scheme, nil for other schemes
+	OAuth2Settings *OAuth2Settings `yaml:"-"`
+
+	// The annotations applied to the settings
+	SettingsAnnotations Annotations `yaml:"-"`
+
+	Annotations Annotations `yaml:",inline"`
+
+	// Where the security scheme has been declared.
+	Position Position `yaml:",inline"`
+
+	// the names of the settings declared, with a value or not
+	settingKeys []string
+}
+
+// UnmarshalYAML decodes a security scheme, along with the annotations of its settings
+func (s *SecurityScheme) UnmarshalYAML(node *yaml.Node) error {
+	type clone SecurityScheme
+	c := clone{}
+	if err := node.Decode(&c); err != nil {
+		return err
+	}
+	*s = SecurityScheme(c)
+	settings := mappingValue(node, "settings")
+	if settings == nil || settings.Kind != yaml.MappingNode {
+		return nil
+	}
+	s.settingKeys = mappingKeys(settings, nil)
+	switch s.Type {
+	case SecuritySchemeOAuth1:
+		s.OAuth1Settings = new(OAuth1Settings)
+		if err := settings.Decode(s.OAuth1Settings); err != nil {
+			return err
+		}
+	case SecuritySchemeOAuth2:
+		s.OAuth2Settings = new(OAuth2Settings)
+		if err := settings.Decode(s.OAuth2Settings); err != nil {
+			return err
+		}
+	}
+	return settings.Decode(&s.SettingsAnnotations)
+}
+
+// Types of security schemes, other than the custom ones, which are prefixed with `x-`
+const (
+	SecuritySchemeOAuth1      = "OAuth 1.0"
+	SecuritySchemeOAuth2      = "OAuth 2.0"
+	SecuritySchemeBasic       = "Basic Authentication"
+	SecuritySchemeDigest      = "Digest Authentication"
+	SecuritySchemePassThrough = "Pass Through"
+)
+
+var securitySchemeTypes = []string{SecuritySchemeOAuth1, SecuritySchemeOAuth2, SecuritySchemeBasic,
+	SecuritySchemeDigest, SecuritySchemePassThrough}
+
+// OAuth1Settings are the settings of an OAuth 1.0 security scheme
+type OAuth1Settings struct {
+	// The URI of the Temporary Credential Request endpoint as defined in RFC5849 Section 2.1
+	RequestTokenURI string `yaml:"requestTokenUri"`
+
+	// The URI of the Resource Owner Authorization endpoint as defined in RFC5849 Section 2.2
+	AuthorizationURI string `yaml:"authorizationUri"`
+
+	// The URI of the Token Request endpoint as defined in RFC5849 Section 2.3
+	TokenCredentialsURI string `yaml:"tokenCredentialsUri"`
+
+	// The signature methods the server supports: HMAC-SHA1, RSA-SHA1 or PLAINTEXT.
+	// All of them if empty.
+	Signatures []string `yaml:"signatures"`
+}
+
+// OAuth2Settings are the settings of an OAuth 2.0 security scheme
+type OAuth2Settings struct {
+	// The URI of the Authorization Endpoint as defined in RFC6749 Section 3.1.
+	// Required by the authorization_code and implicit grant types.
+	AuthorizationURI string `yaml:"authorizationUri"`
+
+	// The URI of the Token Endpoint as defined in RFC6749 Section 3.2
+	AccessTokenURI string `yaml:"accessTokenUri"`
+
+	// The authorization grants the API supports: authorization_code, password,
+	// client_credentials, implicit, or the absolute URI of an extension grant type
+	AuthorizationGrants []string `yaml:"authorizationGrants"`
+
+	// The scopes the API supports
+	Scopes []string `yaml:"scopes"`
+}
+
+// settings required by each type of security scheme
+var requiredSettings = map[string][]string{
+	SecuritySchemeOAuth1: {"requestTokenUri", "authorizationUri", "tokenCredentialsUri"},
+	SecuritySchemeOAuth2: {"accessTokenUri", "authorizationGrants"},
+}
+
+var oauth1Signatures = []string{"HMAC-SHA1", "RSA-SHA1", "PLAINTEXT"}
+
+var oauth2Grants = []string{"authorization_code", "password", "client_credentials", "implicit"}
+
+// check validates the type and the settings of a security scheme declared at path
+func (s *SecurityScheme) check(p *parser, path []string) error {
+	typePath := append(path[:len(path):len(path)], "type")
+	switch {
+	case s.Type == "":
+		return p.errorf(CodeInvalidSecurityScheme, s.Position, typePath, "missing security scheme type")
+	case !containsString(securitySchemeTypes, s.Type) && !strings.HasPrefix(s.Type, "x-"):
+		return p.errorf(CodeInvalidSecurityScheme, s.Position, typePath,
+			"unknown security scheme type %s, custom types have to be prefixed with x-", s.Type)
+	}
+
+	settingsPath := append(path[:len(path):len(path)], "settings")
+	required := requiredSettings[s.Type]
+	if s.OAuth2Settings != nil {
+		for _, grant := range s.OAuth2Settings.AuthorizationGrants {
+			if grant == "authorization_code" || grant == "implicit" {
+				required = append(required[:len(required):len(required)], "authorizationUri")
+				break
+			}
+		}
+	}
+	for _, name := range required {
+		if !containsString(s.settingKeys, name) {
+			if err := p.errorf(CodeInvalidSecurityScheme, s.Position, settingsPath,
+				"missing setting %s of %s security scheme", name, s.Type); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.OAuth1Settings != nil {
+		for _, signature := range s.OAuth1Settings.Signatures {
+			if !containsString(oauth1Signatures, signature) {
+				if err := p.errorf(CodeInvalidSecurityScheme, s.Position, append(settingsPath, "signatures"),
+					"invalid signature %s, expected one of %s", signature, strings.Join(oauth1Signatures, ", ")); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if s.OAuth2Settings != nil {
+		for _, grant := range s.OAuth2Settings.AuthorizationGrants {
+			if u, err := url.Parse(grant); containsString(oauth2Grants, grant) || (err == nil && u.IsAbs()) {
+				continue
+			}
+			if err := p.errorf(CodeInvalidSecurityScheme, s.Position, append(settingsPath, "authorizationGrants"),
+				"invalid authorization grant %s, expected one of %s or an absolute URI",
+				grant, strings.Join(oauth2Grants, ", ")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,171 @@
+package raml
+
+import (
+	"strings"
+)
+
+// This file implements the resolution of the security of the methods of
+// an API: the security schemes securing a method are the ones it's secured
+// by, else the ones of its resource, else the ones of the API, `null`
+// meaning that the method can be called anonymously as well.
+//
+// The headers, query parameters and responses describing the schemes are
+// merged into the method, unless the method declares them itself.
+
+// SecurityRequirement is one of the ways a method can be secured:
+// by a security scheme, along with its parameters, or anonymously.
+type SecurityRequirement struct {
+	// The name of the security scheme, as referred to by securedBy, e.g. `oauth_2_0`
+	// or `lib.oauth_2_0`. Empty when the method can be called anonymously.
+	Name string
+
+	// The security scheme, nil when the method can be called anonymously
+	Scheme *SecurityScheme
+
+	// The parameters of the security scheme, e.g. the scopes of OAuth 2.0
+	Parameters DefinitionParameters
+
+	// Where the requirement has been declared.
+	Position Position
+}
+
+// IsAnonymous checks whether the requirement allows the method to be called anonymously
+func (s SecurityRequirement) IsAnonymous() bool {
+	return s.Scheme == nil
+}
+
+// Scopes returns the OAuth 2.0 scopes the requirement grants access with
+func (s SecurityRequirement) Scopes() []string {
+	return toStrings(s.Parameters["scopes"])
+}
+
+// resolveSecurity resolves the security requirements of all the methods of the API
+func (d *APIDefinition) resolveSecurity(p *parser) error {
+	for name, s := range d.SecuritySchemes {
+		d.SecuritySchemes[name] = s.describedFor(d.MediaType)
+	}
+
+	for _, uri := range d.ResourceOrder {
+		r := d.Resources[uri]
+		if err := d.resolveResourceSecurity(p, &r); err != nil {
+			return err
+		}
+		d.Resources[uri] = r
+	}
+	return nil
+}
+
+// describedFor returns a copy of the security scheme, as described for an API of the given
+// default media types: the bodies of its responses are declared for each of them.
+// The scheme itself is left untouched, the ones of libraries being shared by the APIs using them.
+func (s SecurityScheme) describedFor(mediaTypes []string) SecurityScheme {
+	responses := make(map[HTTPCode]Response, len(s.DescribedBy.Responses))
+	for code, resp := range s.DescribedBy.Responses {
+		bodies := resp.Bodies
+		if bodies.ForMIMEType != nil {
+			bodies.ForMIMEType = make(map[string]Body, len(resp.Bodies.ForMIMEType))
+			for mediaType, body := range resp.Bodies.ForMIMEType {
+				bodies.ForMIMEType[mediaType] = body
+			}
+		}
+		bodies.MediaTypeOrder = append([]string(nil), resp.Bodies.MediaTypeOrder...)
+		resp.Bodies = bodies
+		resp.postProcess(mediaTypes)
+		responses[code] = resp
+	}
+	s.DescribedBy.Responses = responses
+	return s
+}
+
+// resolveResourceSecurity resolves the security requirements of the methods of a resource
+// and of its nested resources
+func (d *APIDefinition) resolveResourceSecurity(p *parser, r *Resource) error {
+	for _, m := range r.Methods {
+		choices, path := m.SecuredBy, r.path(strings.ToLower(m.Name), "securedBy")
+		if choices == nil {
+			choices, path = r.SecuredBy, r.path("securedBy")
+		}
+		if choices == nil {
+			choices, path = d.SecuredBy, []string{"securedBy"}
+		}
+
+		m.Security = nil
+		for _, choice := range choices {
+			if choice.Name == "" {
+				m.Security = append(m.Security, SecurityRequirement{Position: choice.Position})
+				continue
+			}
+			scheme, ok := d.GetSecurityScheme(choice.Name)
+			if !ok {
+				if err := p.errorf(CodeUnknownSecurityScheme, choice.Position, path,
+					"unknown security scheme %s", choice.Name); err != nil {
+					return err
+				}
+				continue
+			}
+			scheme = scheme.describedFor(d.MediaType)
+			m.Security = append(m.Security, SecurityRequirement{
+				Name:       choice.Name,
+				Scheme:     &scheme,
+				Parameters: choice.Parameters,
+				Position:   choice.Position,
+			})
+		}
+
+		// what describes one of several ways to secure the method is optional
+		optional := len(m.Security) > 1
+		for _, s := range m.Security {
+			if !s.IsAnonymous() {
+				m.mergeDescribedBy(s.Scheme.DescribedBy, optional)
+			}
+		}
+	}
+
+	for _, uri := range r.NestedOrder {
+		if err := d.resolveResourceSecurity(p, r.Nested[uri]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeDescribedBy merges the headers, query parameters and responses describing
+// a security scheme into the method, unless the method declares them itself
+func (m *Method) mergeDescribedBy(describedBy DescribedBy, optional bool) {
+	for _, name := range sortedKeys(describedBy.Headers) {
+		if _, ok := m.Headers[HTTPHeader(name)]; ok {
+			continue
+		}
+		if m.Headers == nil {
+			m.Headers = map[HTTPHeader]Header{}
+		}
+		h := describedBy.Headers[HTTPHeader(name)]
+		h.Required = h.Required && !optional
+		m.Headers[HTTPHeader(name)] = h
+		m.HeaderOrder = append(m.HeaderOrder, HTTPHeader(name))
+	}
+
+	for _, name := range sortedKeys(describedBy.QueryParameters) {
+		if _, ok := m.QueryParameters[name]; ok {
+			continue
+		}
+		if m.QueryParameters == nil {
+			m.QueryParameters = map[string]NamedParameter{}
+		}
+		np := describedBy.QueryParameters[name]
+		np.Required = np.Required && !optional
+		m.QueryParameters[name] = np
+		m.QueryParameterOrder = append(m.QueryParameterOrder, name)
+	}
+
+	for _, code := range sortedKeys(describedBy.Responses) {
+		if _, ok := m.Responses[HTTPCode(code)]; ok {
+			continue
+		}
+		if m.Responses == nil {
+			m.Responses = map[HTTPCode]Response{}
+		}
+		m.Responses[HTTPCode(code)] = describedBy.Responses[HTTPCode(code)]
+		m.ResponseOrder = append(m.ResponseOrder, HTTPCode(code))
+	}
+}
@@ -0,0 +1,78 @@
+package raml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolvingSecurity(t *testing.T) {
+	asserter := assert.New(t)
+
+	apiDefinition := new(APIDefinition)
+	diagnostics, err := ParseFileAll("./testdata/security/api.raml", apiDefinition)
+	asserter.Error(err)
+	if asserter.Len(diagnostics, 1) {
+		asserter.Equal(CodeUnknownSecurityScheme, diagnostics[0].Code)
+		asserter.Equal([]string{"/items", "delete", "securedBy"}, diagnostics[0].Path)
+		asserter.Equal(45, diagnostics[0].Position.Line)
+	}
+
+	// secured by the API
+	get := apiDefinition.Resources["/items"].Get
+	if asserter.Len(get.Security, 1) {
+		asserter.Equal("oauth_2_0", get.Security[0].Name)
+		asserter.Equal("OAuth 2.0", get.Security[0].Scheme.Type)
+		asserter.Equal([]string{"read"}, get.Security[0].Scopes())
+		asserter.False(get.Security[0].IsAnonymous())
+	}
+	asserter.True(get.Headers["Authorization"].Required)
+	asserter.True(get.QueryParameters["access_token"].Required)
+	asserter.Equal([]HTTPHeader{"Authorization"}, get.HeaderOrder)
+	// the responses declared by the method are kept
+	asserter.Equal("Not allowed", get.Responses["401"].Description)
+
+	// responses describing the scheme are merged, their bodies expanded
+	nested := apiDefinition.Resources["/items"].Nested["/{id}"].Get
+	asserter.Len(nested.Security, 1)
+	asserter.Equal("Bad or expired token", nested.Responses["401"].Description)
+	asserter.Contains(nested.Responses["401"].Bodies.ForMIMEType, "application/json")
+
+	// secured by the method, in several ways
+	post := apiDefinition.Resources["/items"].Post
+	if asserter.Len(post.Security, 2) {
+		asserter.Equal([]string{"write", "admin"}, post.Security[0].Scopes())
+		asserter.Equal("basic", post.Security[1].Name)
+	}
+	asserter.False(post.Headers["Authorization"].Required)
+	asserter.Equal("", post.Headers["Authorization"].Description)
+
+	// anonymous access
+	public := apiDefinition.Resources["/public"].Get
+	if asserter.Len(public.Security, 1) {
+		asserter.True(public.Security[0].IsAnonymous())
+	}
+	asserter.NotContains(public.Headers, HTTPHeader("Authorization"))
+	asserter.Len(apiDefinition.Resources["/items"].Delete.Security, 1)
+}
+
+func TestResolvingSecurityOfSharedLibraries(t *testing.T) {
+	asserter := assert.New(t)
+
+	cache := NewLibraryCache()
+	parse := func(fileName string) *Method {
+		apiDefinition := new(APIDefinition)
+		asserter.NoError(ParseFileWith("./testdata/security/shared/"+fileName, apiDefinition, WithLibraryCache(cache)))
+		return apiDefinition.Resources["/items"].Get
+	}
+
+	// the responses describing the scheme of the library are expanded for each API
+	json, xml := parse("json.raml"), parse("xml.raml")
+	asserter.Equal([]string{"application/json"}, json.Responses["401"].Bodies.MediaTypeOrder)
+	asserter.Equal([]string{"application/xml"}, xml.Responses["401"].Bodies.MediaTypeOrder)
+
+	lib, ok := cache.get(canonicalLocation("testdata/security/shared/auth.raml"))
+	if asserter.True(ok) {
+		asserter.Empty(lib.SecuritySchemes["token"].DescribedBy.Responses["401"].Bodies.ForMIMEType)
+	}
+}
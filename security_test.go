@@ -0,0 +1,50 @@
+package raml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecuritySchemeSettings(t *testing.T) {
+	asserter := assert.New(t)
+
+	// typed settings
+	apiDefinition := new(APIDefinition)
+	diagnostics, err := ParseFileAll("./testdata/security/schemes.raml", apiDefinition)
+	asserter.Error(err)
+
+	oauth1 := apiDefinition.SecuritySchemes["oauth_1_0"]
+	asserter.Nil(oauth1.OAuth2Settings)
+	asserter.Equal("https://example.com/request", oauth1.OAuth1Settings.RequestTokenURI)
+	asserter.Equal([]string{"HMAC-SHA1", "MD5"}, oauth1.OAuth1Settings.Signatures)
+
+	oauth2 := apiDefinition.SecuritySchemes["oauth_2_0"]
+	asserter.Nil(oauth2.OAuth1Settings)
+	asserter.Equal("https://example.com/token", oauth2.OAuth2Settings.AccessTokenURI)
+	asserter.Equal([]string{"read", "write"}, oauth2.OAuth2Settings.Scopes)
+
+	custom := apiDefinition.SecuritySchemes["custom"]
+	asserter.Nil(custom.OAuth1Settings)
+	asserter.Nil(custom.OAuth2Settings)
+	asserter.Equal("X-Api-Key", custom.Settings["header"])
+
+	// validation
+	var messages []string
+	for _, d := range diagnostics {
+		asserter.Equal(CodeInvalidSecurityScheme, d.Code)
+		messages = append(messages, d.Message)
+	}
+	asserter.Equal([]string{
+		"unknown security scheme type Kerberos, custom types have to be prefixed with x-",
+		"missing setting tokenCredentialsUri of OAuth 1.0 security scheme",
+		"invalid signature MD5, expected one of HMAC-SHA1, RSA-SHA1, PLAINTEXT",
+		"missing setting authorizationUri of OAuth 2.0 security scheme",
+		"invalid authorization grant refresh, expected one of authorization_code, password, client_credentials, implicit or an absolute URI",
+	}, messages)
+	if asserter.Len(diagnostics, 5) {
+		asserter.Equal([]string{"securitySchemes", "kerberos", "type"}, diagnostics[0].Path)
+		asserter.Equal([]string{"securitySchemes", "oauth_2_0", "settings", "authorizationGrants"}, diagnostics[4].Path)
+	}
+
+}
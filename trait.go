@@ -2,6 +2,8 @@ package raml
 
 import (
 	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 // A Trait is a partial method definition that, like a method, can provide
@@ -48,6 +50,25 @@ type Trait struct {
 	OptionalHeaders         map[HTTPHeader]Header     `yaml:"headers?"`
 	OptionalResponses       map[HTTPCode]Response     `yaml:"responses?"`
 	OptionalQueryParameters map[string]NamedParameter `yaml:"queryParameters?"`
+
+	// Annotations applied to the trait itself, which are not inherited by the methods.
+	Annotations Annotations `yaml:",inline"`
+
+	// Where the trait has been declared.
+	Position Position `yaml:",inline"`
+
+	// the declaration, merged into the methods the trait is applied to
+	node *yaml.Node
+}
+
+// UnmarshalYAML decodes a trait, keeping its declaration to be merged into methods
+func (t *Trait) UnmarshalYAML(node *yaml.Node) error {
+	type clone Trait
+	c := clone{}
+	err := node.Decode(&c)
+	*t = Trait(c)
+	t.node = node
+	return err
 }
 
 func (t *Trait) postProcess(name string) {
@@ -56,12 +77,8 @@ func (t *Trait) postProcess(name string) {
 
 // init trait dicts
 // trait dicts contain current trait parameters that is currently applied to a method
-func initTraitDicts(r *Resource, m *Method, dicts map[string]interface{}) map[string]interface{} {
-	dicts = initResourceTypeDicts(r, dicts)
-	dicts["methodName"] = strings.ToLower(m.Name)
+func initTraitDicts(r *Resource, methodName string, params DefinitionParameters) map[string]interface{} {
+	dicts := initResourceTypeDicts(r, substituteParameters(params, nil))
+	dicts["methodName"] = strings.ToLower(methodName)
 	return dicts
 }
-
-func optionalTraitProperty(name string) bool {
-	return strings.HasSuffix(name, "?")
-}
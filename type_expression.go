@@ -0,0 +1,284 @@
+package raml
+
+import (
+	"fmt"
+	"strings"
+)
+
+// This file implements the parsing of RAML type expressions, such as
+// `(Cat | Dog)[]` or `lib.Person[] | nil`, into a syntax tree:
+//
+//	union   = postfix { "|" postfix }
+//	postfix = primary { "[" "]" | "?" }
+//	primary = name | "(" union ")"
+//
+// Whitespace is insignificant, and a name can be qualified by the name of
+// the library declaring the type, e.g. `lib.Person`. A trailing `?` is the
+// shorthand of a union with nil: `Person?` is parsed as `Person | nil`.
+
+// TypeExpression is a node of the syntax tree of a type expression:
+// a *NamedType, an *ArrayType, a *UnionType or a *GroupType.
+type TypeExpression interface {
+	// String returns the expression, formatted canonically
+	String() string
+
+	typeExpression()
+}
+
+// NamedType refers to a type by its name, e.g. `Person` or `lib.Person`
+type NamedType struct {
+	// The name of the library declaring the type, empty for the types of
+	// the API and the built-in types
+	Library string
+
+	// The name of the type in its library
+	Name string
+}
+
+// ArrayType is an array of the items type, e.g. `Person[]`
+type ArrayType struct {
+	Items TypeExpression
+}
+
+// UnionType is the union of several types, e.g. `Cat | Dog`
+type UnionType struct {
+	Types []TypeExpression
+}
+
+// GroupType is an expression grouped by parentheses, e.g. `(Cat | Dog)`
+type GroupType struct {
+	Expression TypeExpression
+}
+
+func (*NamedType) typeExpression() {}
+func (*ArrayType) typeExpression() {}
+func (*UnionType) typeExpression() {}
+func (*GroupType) typeExpression() {}
+
+// QualifiedName returns the name of the type qualified by the name of its library, if any
+func (n *NamedType) QualifiedName() string {
+	if n.Library == "" {
+		return n.Name
+	}
+	return n.Library + "." + n.Name
+}
+
+func (n *NamedType) String() string {
+	return n.QualifiedName()
+}
+
+func (a *ArrayType) String() string {
+	if _, ok := a.Items.(*UnionType); ok {
+		return "(" + a.Items.String() + ")[]"
+	}
+	return a.Items.String() + "[]"
+}
+
+func (u *UnionType) String() string {
+	types := make([]string, len(u.Types))
+	for i, t := range u.Types {
+		types[i] = t.String()
+	}
+	return strings.Join(types, " | ")
+}
+
+func (g *GroupType) String() string {
+	return "(" + g.Expression.String() + ")"
+}
+
+// ParseTypeExpression parses a type expression, e.g. `(Cat | Dog)[]`
+func ParseTypeExpression(expr string) (TypeExpression, error) {
+	p := typeExpressionParser{tokens: tokenizeTypeExpression(expr)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty type expression")
+	}
+	e, err := p.union()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return e, nil
+}
+
+// ungroup returns the expression grouped by parentheses, if any
+func ungroup(e TypeExpression) TypeExpression {
+	for {
+		g, ok := e.(*GroupType)
+		if !ok {
+			return e
+		}
+		e = g.Expression
+	}
+}
+
+// walkTypeExpression calls fn for an expression and all of its descendants
+func walkTypeExpression(e TypeExpression, fn func(TypeExpression)) {
+	fn(e)
+	switch v := e.(type) {
+	case *ArrayType:
+		walkTypeExpression(v.Items, fn)
+	case *UnionType:
+		for _, t := range v.Types {
+			walkTypeExpression(t, fn)
+		}
+	case *GroupType:
+		walkTypeExpression(v.Expression, fn)
+	}
+}
+
+// typeExpressionOf parses the value of a type facet when it's a type
+// expression, returning false for inline declarations, schemas and
+// invalid expressions
+func typeExpressionOf(tip interface{}) (TypeExpression, bool) {
+	e, err := parseTypeFacet(tip)
+	return e, err == nil
+}
+
+// arrayItemsOf returns the expression of the items of an array type expression
+func arrayItemsOf(tip interface{}) (TypeExpression, bool) {
+	e, ok := typeExpressionOf(tip)
+	if !ok {
+		return nil, false
+	}
+	a, ok := ungroup(e).(*ArrayType)
+	if !ok {
+		return nil, false
+	}
+	return ungroup(a.Items), true
+}
+
+// unionOf returns the expressions of the members of a union type expression
+func unionOf(tip interface{}) ([]TypeExpression, bool) {
+	e, ok := typeExpressionOf(tip)
+	if !ok {
+		return nil, false
+	}
+	u, ok := ungroup(e).(*UnionType)
+	if !ok {
+		return nil, false
+	}
+	return u.Types, true
+}
+
+// isTypeExpressionPunctuation checks whether a character separates the names of a type expression
+func isTypeExpressionPunctuation(r rune) bool {
+	return strings.ContainsRune("()|[]?", r)
+}
+
+// tokenizeTypeExpression splits a type expression into names and punctuation
+func tokenizeTypeExpression(expr string) []string {
+	var tokens []string
+	name := strings.Builder{}
+	flush := func() {
+		if name.Len() > 0 {
+			tokens = append(tokens, name.String())
+			name.Reset()
+		}
+	}
+	for _, r := range expr {
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			flush()
+		case isTypeExpressionPunctuation(r):
+			flush()
+			tokens = append(tokens, string(r))
+		default:
+			name.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// typeExpressionParser is a recursive descent parser of type expressions
+type typeExpressionParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *typeExpressionParser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	return tok
+}
+
+func (p *typeExpressionParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *typeExpressionParser) union() (TypeExpression, error) {
+	e, err := p.postfix()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "|" {
+		return e, nil
+	}
+	u := &UnionType{Types: []TypeExpression{e}}
+	for p.peek() == "|" {
+		p.next()
+		if e, err = p.postfix(); err != nil {
+			return nil, err
+		}
+		u.Types = append(u.Types, e)
+	}
+	return u, nil
+}
+
+func (p *typeExpressionParser) postfix() (TypeExpression, error) {
+	e, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "[" || p.peek() == "?" {
+		if p.next() == "?" {
+			e = &UnionType{Types: []TypeExpression{e, &NamedType{Name: "nil"}}}
+			continue
+		}
+		if tok := p.next(); tok != "]" {
+			return nil, fmt.Errorf("expected \"]\", got %q", tok)
+		}
+		e = &ArrayType{Items: e}
+	}
+	return e, nil
+}
+
+func (p *typeExpressionParser) primary() (TypeExpression, error) {
+	switch tok := p.next(); tok {
+	case "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case "(":
+		e, err := p.union()
+		if err != nil {
+			return nil, err
+		}
+		if tok = p.next(); tok != ")" {
+			return nil, fmt.Errorf("expected \")\", got %q", tok)
+		}
+		return &GroupType{Expression: e}, nil
+	case ")", "|", "[", "]", "?":
+		return nil, fmt.Errorf("unexpected %q", tok)
+	default:
+		return newNamedType(tok)
+	}
+}
+
+// newNamedType returns the reference to the type named name, qualified or not
+func newNamedType(name string) (*NamedType, error) {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return &NamedType{Name: name}, nil
+	}
+	if i == 0 || i == len(name)-1 {
+		return nil, fmt.Errorf("invalid type name %q", name)
+	}
+	return &NamedType{Library: name[:i], Name: name[i+1:]}, nil
+}
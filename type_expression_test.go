@@ -0,0 +1,106 @@
+package raml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsingTypeExpressions(t *testing.T) {
+	asserter := assert.New(t)
+
+	// expressions are parsed whatever their whitespace, and formatted canonically
+	expressions := map[string]string{
+		"Person":               "Person",
+		"lib.Person":           "lib.Person",
+		"Person [ ] []":        "Person[][]",
+		"Cat|Dog":              "Cat | Dog",
+		"( Cat | Dog )[]":      "(Cat | Dog)[]",
+		"lib.Person[] | nil":   "lib.Person[] | nil",
+		"((a.b.Cat))":          "((a.b.Cat))",
+		"(Cat | Dog)[] | Bird": "(Cat | Dog)[] | Bird",
+		"Person?":              "Person | nil",
+		"Person[] ?":           "Person[] | nil",
+		"(Cat | Dog)?":         "(Cat | Dog) | nil",
+		"Cat? | Dog":           "Cat | nil | Dog",
+		"string?[]":            "(string | nil)[]",
+	}
+	for expr, formatted := range expressions {
+		e, err := ParseTypeExpression(expr)
+		if asserter.NoError(err, expr) {
+			asserter.Equal(formatted, e.String(), expr)
+		}
+	}
+
+	// the syntax tree
+	e, err := ParseTypeExpression("(Cat | lib.Dog)[] | nil")
+	asserter.NoError(err)
+	union, ok := e.(*UnionType)
+	asserter.True(ok)
+	asserter.Len(union.Types, 2)
+	array, ok := union.Types[0].(*ArrayType)
+	asserter.True(ok)
+	group, ok := array.Items.(*GroupType)
+	asserter.True(ok)
+	members := group.Expression.(*UnionType).Types
+	asserter.Equal(&NamedType{Name: "Cat"}, members[0])
+	asserter.Equal(&NamedType{Library: "lib", Name: "Dog"}, members[1])
+	asserter.Equal(&NamedType{Name: "nil"}, union.Types[1])
+
+	// invalid expressions
+	for _, expr := range []string{"", "Cat |", "(Cat", "Cat]", "Cat[", "| Dog", "lib.", "Cat Dog", "?", "Cat | ?"} {
+		_, err := ParseTypeExpression(expr)
+		asserter.Error(err, expr)
+	}
+}
+
+func TestTypeExpressions(t *testing.T) {
+	asserter := assert.New(t)
+
+	apiDefinition := new(APIDefinition)
+	asserter.NoError(ParseFile("./testdata/type_expressions.raml", apiDefinition))
+
+	// types declared by their type expression only
+	pets := apiDefinition.Types["Pets"]
+	asserter.Equal("(Cat | Dog)[]", pets.Type)
+	asserter.True(pets.IsArray())
+	asserter.False(pets.IsUnion())
+	asserter.Equal("Cat | Dog", pets.ArrayType())
+
+	people := apiDefinition.Types["People"]
+	asserter.True(people.IsUnion())
+	asserter.False(people.IsArray())
+	union, _ := people.Union()
+	asserter.Equal([]string{"lib.Id[]", "nil"}, union)
+
+	nickname := apiDefinition.Types["Nickname"]
+	asserter.True(nickname.IsUnion())
+	union, _ = nickname.Union()
+	asserter.Equal([]string{"string", "nil"}, union)
+
+	grid := apiDefinition.Types["Grid"]
+	asserter.True(grid.IsArray())
+	asserter.True(grid.IsBidimensionalArray())
+	asserter.Equal("string", grid.BidimensiArrayType())
+
+	e, err := apiDefinition.Types["Cat"].TypeExpression()
+	asserter.NoError(err)
+	asserter.Equal(&NamedType{Name: "object"}, e)
+
+	// properties
+	owner := apiDefinition.Types["Owner"]
+	asserter.True(owner.GetProperty("pets").IsArray())
+	asserter.False(owner.GetProperty("pets").IsUnion())
+	asserter.Equal("Cat | Dog", owner.GetProperty("pets").ArrayType())
+	asserter.Equal("lib.Id", owner.GetProperty("friends").ArrayType())
+	asserter.False(isPropTypeSupported(owner.GetProperty("pets")))
+	asserter.True(isPropTypeSupported(owner.GetProperty("friends")))
+
+	// arrays declared by their items
+	body := apiDefinition.Resources["/pets"].Get.Responses["200"].Bodies.ForMIMEType["application/json"]
+	asserter.Equal("(Cat | Dog)[]", body.Type)
+	asserter.Nil(body.Items)
+
+	// JSON schemas
+	asserter.Equal(&arrayItem{Ref: "lib.Id" + fileSuffix}, NewJSONSchema(Type{typeProps: typeProps{Type: "lib.Id []"}}, "Ids").Items)
+}
@@ -0,0 +1,676 @@
+package raml
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// This file implements the resolution of the types of an API against the
+// types they inherit from, following the RAML 1.0 specification:
+//   - a type declared by a type expression inherits from the types it refers to,
+//     a type declared by a sequence of types from each of them, which can only
+//     be object types (multiple inheritance)
+//   - a type without type inherits from object when it declares properties,
+//     from array when it declares items, and from string otherwise
+//   - the properties of the parents are inherited, a property declared again
+//     having to be of a subtype of the inherited one
+//   - the facets of the parents are inherited and can be overridden, but for
+//     the user-defined facets a parent has given a value to
+//
+// Types can refer to themselves through their properties, not through the
+// types they inherit from.
+
+// TypeKind is the kind of the instances of a type
+type TypeKind string
+
+// Kinds of types
+const (
+	KindAny    TypeKind = "any"
+	KindScalar TypeKind = "scalar"
+	KindObject TypeKind = "object"
+	KindArray  TypeKind = "array"
+	KindUnion  TypeKind = "union"
+)
+
+// built-in scalar types
+var builtinScalarTypes = []string{"string", "number", "integer", "boolean", "date-only", "time-only",
+	"datetime-only", "datetime", "file", "nil"}
+
+// keys of a type declaration which are not facets inherited by its subtypes
+var nonFacetKeys = []string{"type", "schema", "properties", "items", "facets", "example", "examples",
+	"displayName", "description", "required", "discriminatorValue"}
+
+// ResolvedType is a type resolved against the types it inherits from
+type ResolvedType struct {
+	// The qualified name of the type, e.g. `lib.Person`, or the name of
+	// a built-in type. Empty for an inline declaration.
+	Name string
+
+	// The kind of the instances of the type
+	Kind TypeKind
+
+	// The built-in type the type derives from, e.g. `string` or `object`,
+	// `union` for union types
+	Base string
+
+	// The types the type directly inherits from
+	Parents []*ResolvedType
+
+	// The properties of an object type, declared by the type or inherited, by name
+	Properties map[string]*ResolvedProperty
+
+	// The type of the items of an array type, nil if not declared
+	Items *ResolvedType
+
+	// The types of a union type
+	Members []*ResolvedType
+
+	// The facets of the type, declared by the type or inherited, by name.
+	// Built-in facets, such as minLength, as well as user-defined ones.
+	Facets map[string]interface{}
+
+	// The user-defined facets the type and its parents declare, by name
+	FacetDeclarations map[string]interface{}
+
+	// Where the type has been declared.
+	Position Position
+
+	declaration map[string]interface{} // the facets declared by the type itself
+	scope       string                 // the qualified name of the type the names of the declaration refer from
+	path        []string
+	state       resolutionState
+}
+
+// ResolvedProperty is a property of a resolved object type
+type ResolvedProperty struct {
+	Name     string
+	Required bool
+	Type     *ResolvedType
+}
+
+// resolutionState tells how far a type has been resolved
+type resolutionState int
+
+const (
+	unresolved resolutionState = iota
+	inheriting                 // the types it inherits from are being resolved
+	inherited                  // its kind and parents are known
+	completing                 // its facets and properties are being resolved
+	resolved
+)
+
+// IsSubtypeOf checks whether the instances of the type are instances of other
+func (t *ResolvedType) IsSubtypeOf(other *ResolvedType) bool {
+	switch {
+	case other == nil || t == other || other.Kind == KindAny:
+		return true
+	case t.inherits(other):
+		return true
+	case t.Kind == KindUnion:
+		for _, m := range t.Members {
+			if !m.IsSubtypeOf(other) {
+				return false
+			}
+		}
+		return true
+	case other.Kind == KindUnion:
+		for _, m := range other.Members {
+			if t.IsSubtypeOf(m) {
+				return true
+			}
+		}
+		return false
+	case other.Name != "" && !isBuiltinTypeName(other.Name):
+		// a named type is only extended by inheriting from it
+		return false
+	case t.Kind != other.Kind:
+		return false
+	}
+
+	switch t.Kind {
+	case KindScalar:
+		return t.Base == other.Base || (t.Base == "integer" && other.Base == "number")
+	case KindArray:
+		return t.Items == nil || t.Items.IsSubtypeOf(other.Items)
+	case KindObject:
+		for name, p := range other.Properties {
+			tp, ok := t.Properties[name]
+			if !ok {
+				if p.Required {
+					return false
+				}
+				continue
+			}
+			if tp.Type != p.Type && !tp.Type.IsSubtypeOf(p.Type) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// inherits checks whether other is one of the ancestors of the type
+func (t *ResolvedType) inherits(other *ResolvedType) bool {
+	for _, parent := range t.Parents {
+		if parent == other || parent.inherits(other) {
+			return true
+		}
+	}
+	return false
+}
+
+// displayName returns the name of the type for messages
+func (t *ResolvedType) displayName() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return "inline " + t.Base + " type"
+}
+
+func isBuiltinTypeName(name string) bool {
+	return containsString(builtinScalarTypes, name) || name == "any" || name == "object" || name == "array"
+}
+
+// resolveTypes resolves the types of the API and of the libraries it uses
+func (d *APIDefinition) resolveTypes(p *parser) error {
+	r := newTypeResolver(p, d)
+	if err := r.resolveAll(); err != nil {
+		return err
+	}
+	d.ResolvedTypes = r.types
+	return nil
+}
+
+// resolveAll resolves all the declared types
+func (r *typeResolver) resolveAll() error {
+	names := make([]string, 0, len(r.declarations))
+	for name := range r.declarations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t, err := r.named(name, nil)
+		if err != nil {
+			return err
+		}
+		if err = r.complete(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// typeResolver resolves types against the types they inherit from
+type typeResolver struct {
+	p            *parser
+	declarations map[string]Type          // all types, by qualified name
+	types        map[string]*ResolvedType // the resolved declarations, by qualified name
+	builtins     map[string]*ResolvedType
+	chain        []string // the types being inherited from, for cycles to be reported
+}
+
+func newTypeResolver(p *parser, d *APIDefinition) *typeResolver {
+	r := &typeResolver{
+		p:            p,
+		declarations: map[string]Type{},
+		types:        map[string]*ResolvedType{},
+		builtins:     map[string]*ResolvedType{},
+	}
+	for name, t := range d.Types {
+		r.declarations[name] = t
+	}
+	for libName, lib := range d.allLibraries(map[string]*Library{}, d.Libraries) {
+		for name, t := range lib.Types {
+			r.declarations[libName+"."+name] = t
+		}
+	}
+	return r
+}
+
+// builtin returns the built-in type named name
+func (r *typeResolver) builtin(name string) (*ResolvedType, bool) {
+	if t, ok := r.builtins[name]; ok {
+		return t, true
+	}
+	var kind TypeKind
+	switch {
+	case containsString(builtinScalarTypes, name):
+		kind = KindScalar
+	case name == "any" || name == "object" || name == "array":
+		kind = TypeKind(name)
+	default:
+		return nil, false
+	}
+	t := &ResolvedType{Name: name, Kind: kind, Base: name, state: resolved}
+	r.builtins[name] = t
+	return t, true
+}
+
+// named returns the type named name, as referred to by the type from,
+// once the types it inherits from resolved
+func (r *typeResolver) named(name string, from *ResolvedType) (*ResolvedType, error) {
+	if t, ok := r.builtin(name); ok {
+		return t, nil
+	}
+	scope := ""
+	if from != nil {
+		scope = from.scope
+	}
+	qualified := scopedName(scope, name, func(qualified string) bool {
+		_, ok := r.declarations[qualified]
+		return ok
+	})
+
+	if t, ok := r.types[qualified]; ok {
+		if t.state != inheriting {
+			return t, nil
+		}
+		cycle := append(r.chain[indexOfString(r.chain, qualified):], qualified)
+		anyType, _ := r.builtin("any")
+		return anyType, r.errorf(CodeCircularType, t, "circular inheritance: %s", strings.Join(cycle, " -> "))
+	}
+
+	decl, ok := r.declarations[qualified]
+	if !ok {
+		anyType, _ := r.builtin("any")
+		return anyType, r.errorf(CodeInvalidType, from, "unknown type %s", name)
+	}
+	t := &ResolvedType{
+		Name:        qualified,
+		Position:    decl.Position,
+		declaration: decl.facetMap(),
+		scope:       qualified,
+		path:        typePath(qualified),
+	}
+	r.types[qualified] = t
+	return t, r.inherit(t)
+}
+
+// typePath returns the path of the declaration of the type qualified as name
+func typePath(name string) []string {
+	var path []string
+	parts := strings.Split(name, ".")
+	for _, lib := range parts[:len(parts)-1] {
+		path = append(path, "uses", lib)
+	}
+	return append(path, "types", parts[len(parts)-1])
+}
+
+// declaration returns the type of an inline declaration made by the type from
+func (r *typeResolver) declaration(decl map[string]interface{}, from *ResolvedType) (*ResolvedType, error) {
+	t := &ResolvedType{declaration: decl}
+	if from != nil {
+		t.Position, t.scope, t.path = from.Position, from.scope, from.path
+	}
+	return t, r.inherit(t)
+}
+
+// typeOf returns the type declared by the value of a property or of items,
+// either a type expression or an inline declaration
+func (r *typeResolver) typeOf(value interface{}, from *ResolvedType) (*ResolvedType, error) {
+	switch v := value.(type) {
+	case nil:
+		t, _ := r.builtin("string")
+		return t, nil
+	case map[string]interface{}:
+		return r.declaration(v, from)
+	case Property:
+		return r.declaration(v.facetMap(), from)
+	case string:
+		if isSchemaType(v) {
+			anyType, _ := r.builtin("any")
+			return anyType, nil
+		}
+		e, err := ParseTypeExpression(v)
+		if err != nil {
+			// reported when checking the type expressions of the declaration
+			anyType, _ := r.builtin("any")
+			return anyType, nil
+		}
+		return r.expression(e, from)
+	}
+	return r.declaration(map[string]interface{}{"type": value}, from)
+}
+
+// expression returns the type of a type expression used by the type from
+func (r *typeResolver) expression(e TypeExpression, from *ResolvedType) (*ResolvedType, error) {
+	t := &ResolvedType{state: inherited}
+	if from != nil {
+		t.Position, t.scope, t.path = from.Position, from.scope, from.path
+	}
+	switch v := e.(type) {
+	case *NamedType:
+		return r.named(v.QualifiedName(), from)
+	case *GroupType:
+		return r.expression(v.Expression, from)
+	case *ArrayType:
+		items, err := r.expression(v.Items, from)
+		if err != nil {
+			return nil, err
+		}
+		array, _ := r.builtin("array")
+		t.Kind, t.Base, t.Parents, t.Items = KindArray, "array", []*ResolvedType{array}, items
+	case *UnionType:
+		t.Kind, t.Base = KindUnion, "union"
+		for _, m := range v.Types {
+			member, err := r.expression(m, from)
+			if err != nil {
+				return nil, err
+			}
+			t.Members = append(t.Members, member)
+		}
+	}
+	return t, nil
+}
+
+// inherit resolves the types a type inherits from, and so its kind
+func (r *typeResolver) inherit(t *ResolvedType) error {
+	t.state = inheriting
+	r.chain = append(r.chain, t.Name)
+	defer func() {
+		r.chain = r.chain[:len(r.chain)-1]
+		t.state = inherited
+	}()
+
+	decl := t.declaration
+	tip, ok := decl["type"]
+	if !ok {
+		tip = decl["schema"]
+	}
+	var parents []*ResolvedType
+	switch v := tip.(type) {
+	case nil:
+		name := "string"
+		if _, ok := decl["properties"]; ok {
+			name = "object"
+		} else if _, ok := decl["items"]; ok {
+			name = "array"
+		}
+		parent, _ := r.builtin(name)
+		parents = append(parents, parent)
+	case []interface{}:
+		for _, item := range v {
+			parent, err := r.typeOf(item, t)
+			if err != nil {
+				return err
+			}
+			parents = append(parents, parent)
+		}
+	default:
+		parent, err := r.typeOf(v, t)
+		if err != nil {
+			return err
+		}
+		parents = append(parents, parent)
+	}
+	t.Parents = parents
+
+	if len(parents) == 1 {
+		parent := parents[0]
+		t.Kind, t.Base, t.Items, t.Members = parent.Kind, parent.Base, parent.Items, parent.Members
+	} else {
+		t.Kind, t.Base = KindObject, "object"
+		for _, parent := range parents {
+			if parent.Kind != KindObject && parent.Kind != KindAny {
+				if err := r.errorf(CodeInvalidInheritance, t, "%s can only inherit from several object types, not from %s",
+					t.displayName(), parent.displayName()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if items, ok := decl["items"]; ok && t.Kind == KindArray {
+		var err error
+		if t.Items, err = r.typeOf(items, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// complete resolves the facets and properties of a type, and of the types it's made of
+func (r *typeResolver) complete(t *ResolvedType) error {
+	if t == nil || t.state >= completing {
+		return nil
+	}
+	t.state = completing
+	defer func() { t.state = resolved }()
+
+	for _, parent := range t.Parents {
+		if err := r.complete(parent); err != nil {
+			return err
+		}
+	}
+	if err := r.complete(t.Items); err != nil {
+		return err
+	}
+	for _, m := range t.Members {
+		if err := r.complete(m); err != nil {
+			return err
+		}
+	}
+	if err := r.completeFacets(t); err != nil {
+		return err
+	}
+	if t.Kind == KindObject {
+		return r.completeProperties(t)
+	}
+	return nil
+}
+
+// completeFacets inherits the facets of the parents of a type, and overrides them
+func (r *typeResolver) completeFacets(t *ResolvedType) error {
+	t.Facets = map[string]interface{}{}
+	t.FacetDeclarations = map[string]interface{}{}
+	fixedBy := map[string]*ResolvedType{}
+	for _, parent := range t.Parents {
+		for name, value := range parent.Facets {
+			t.Facets[name] = value
+		}
+		for name, decl := range parent.FacetDeclarations {
+			t.FacetDeclarations[name] = decl
+			if _, ok := parent.Facets[name]; ok {
+				fixedBy[name] = parent
+			}
+		}
+	}
+
+	if declared, ok := t.declaration["facets"].(map[string]interface{}); ok {
+		for _, name := range sortedKeys(declared) {
+			if _, ok := t.FacetDeclarations[name]; ok {
+				if err := r.errorf(CodeInvalidInheritance, t, "facet %s is already declared by a parent of %s",
+					name, t.displayName()); err != nil {
+					return err
+				}
+				continue
+			}
+			t.FacetDeclarations[name] = declared[name]
+		}
+	}
+
+	for _, name := range sortedKeys(t.declaration) {
+		value := t.declaration[name]
+		if containsString(nonFacetKeys, name) || strings.HasPrefix(name, "(") {
+			continue
+		}
+		if parent, ok := fixedBy[name]; ok && !reflect.DeepEqual(value, t.Facets[name]) {
+			if err := r.errorf(CodeInvalidInheritance, t, "facet %s has been given the value %v by %s, it can't be changed",
+				name, t.Facets[name], parent.displayName()); err != nil {
+				return err
+			}
+			continue
+		}
+		t.Facets[name] = value
+	}
+	return nil
+}
+
+// completeProperties inherits the properties of the parents of an object type, and declares its own
+func (r *typeResolver) completeProperties(t *ResolvedType) error {
+	t.Properties = map[string]*ResolvedProperty{}
+	inheritedFrom := map[string]*ResolvedType{}
+	for _, parent := range t.Parents {
+		for _, name := range sortedKeys(parent.Properties) {
+			p := parent.Properties[name]
+			if existing, ok := t.Properties[name]; ok && !sameType(existing.Type, p.Type) {
+				if err := r.errorf(CodeInvalidInheritance, t, "property %s is inherited from %s and %s with different types",
+					name, inheritedFrom[name].displayName(), parent.displayName()); err != nil {
+					return err
+				}
+				continue
+			}
+			t.Properties[name] = p
+			inheritedFrom[name] = parent
+		}
+	}
+
+	props, _ := t.declaration["properties"].(map[string]interface{})
+	for _, key := range sortedKeys(props) {
+		name, required, value := propertyDeclaration(key, props[key])
+		pt, err := r.typeOf(value, t)
+		if err != nil {
+			return err
+		}
+		if err = r.complete(pt); err != nil {
+			return err
+		}
+		if inherited, ok := t.Properties[name]; ok && pt != inherited.Type && !pt.IsSubtypeOf(inherited.Type) {
+			if err = r.errorf(CodeInvalidInheritance, t, "property %s of %s has to be of a subtype of %s, as inherited from %s",
+				name, t.displayName(), inherited.Type.displayName(), inheritedFrom[name].displayName()); err != nil {
+				return err
+			}
+			continue
+		}
+		t.Properties[name] = &ResolvedProperty{Name: name, Required: required, Type: pt}
+	}
+	return nil
+}
+
+// propertyDeclaration returns the name of a property declared by key, whether it's required
+// and its declaration, without its required facet
+func propertyDeclaration(key string, value interface{}) (string, bool, interface{}) {
+	name, required := key, true
+	switch {
+	case isPatternProperty(key):
+		required = false
+	case strings.HasSuffix(key, "?"):
+		name, required = strings.TrimSuffix(key, "?"), false
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		decl := make(map[string]interface{}, len(v))
+		for k, facet := range v {
+			decl[k] = facet
+		}
+		if req, ok := decl["required"].(bool); ok {
+			required = req
+		}
+		delete(decl, "required")
+		return name, required, decl
+	case Property:
+		return name, v.Required && required, v
+	}
+	return name, required, value
+}
+
+// isPatternProperty checks whether a property name is a regular expression,
+// declaring the properties of which names match it, e.g. `/^note\d+$/`
+func isPatternProperty(name string) bool {
+	return len(name) >= 2 && strings.HasPrefix(name, "/") && strings.HasSuffix(name, "/")
+}
+
+// sameType checks whether two types have the same instances
+func sameType(a, b *ResolvedType) bool {
+	return a == b || (a.IsSubtypeOf(b) && b.IsSubtypeOf(a))
+}
+
+func (r *typeResolver) errorf(code string, t *ResolvedType, format string, args ...interface{}) error {
+	if t == nil {
+		return r.p.errorf(code, Position{}, nil, format, args...)
+	}
+	return r.p.errorf(code, t.Position, t.path, format, args...)
+}
+
+// facetMap returns the facets declared by the type, once post processed
+func (t *Type) facetMap() map[string]interface{} {
+	facets := make(map[string]interface{}, len(t.declaration)+2)
+	for name, value := range t.declaration {
+		facets[name] = value
+	}
+	if t.Type != nil {
+		facets["type"] = t.Type
+	}
+	if t.Properties != nil {
+		facets["properties"] = t.Properties
+	}
+	return facets
+}
+
+// facetMap returns the facets declared by the property
+func (p Property) facetMap() map[string]interface{} {
+	facets := map[string]interface{}{"type": p.Type}
+	if p.Enum != nil {
+		facets["enum"] = p.Enum
+	}
+	if p.Pattern != nil {
+		facets["pattern"] = *p.Pattern
+	}
+	if p.MinLength != nil {
+		facets["minLength"] = *p.MinLength
+	}
+	if p.MaxLength != nil {
+		facets["maxLength"] = *p.MaxLength
+	}
+	if p.Minimum != nil {
+		facets["minimum"] = *p.Minimum
+	}
+	if p.Maximum != nil {
+		facets["maximum"] = *p.Maximum
+	}
+	if p.MultipleOf != nil {
+		facets["multipleOf"] = *p.MultipleOf
+	}
+	if p.MinItems != nil {
+		facets["minItems"] = *p.MinItems
+	}
+	if p.MaxItems != nil {
+		facets["maxItems"] = *p.MaxItems
+	}
+	if p.UniqueItems {
+		facets["uniqueItems"] = true
+	}
+	if p.Format != nil {
+		facets["format"] = *p.Format
+	}
+	if p.Items.Type != "" {
+		facets["items"] = p.Items.Type
+	}
+	return facets
+}
+
+// sortedKeys returns the keys of a map of strings, sorted
+func sortedKeys(m interface{}) []string {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Map {
+		return nil
+	}
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// indexOfString returns the index of s in a, -1 if not found
+func indexOfString(a []string, s string) int {
+	for i, v := range a {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,75 @@
+package raml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolvingTypes(t *testing.T) {
+	asserter := assert.New(t)
+
+	apiDefinition := new(APIDefinition)
+	asserter.NoError(ParseFile("./testdata/types/hierarchy.raml", apiDefinition))
+	types := apiDefinition.ResolvedTypes
+
+	// types of the libraries are resolved too
+	asserter.Contains(types, "lib.Id")
+	asserter.Equal(KindScalar, types["lib.Id"].Kind)
+
+	// scalar types inherit the facets of their parents, and override them
+	short := types["ShortName"]
+	asserter.Equal(KindScalar, short.Kind)
+	asserter.Equal("string", short.Base)
+	asserter.Equal([]*ResolvedType{types["Name"]}, short.Parents)
+	asserter.Equal(1, short.Facets["minLength"])
+	asserter.Equal(16, short.Facets["maxLength"])
+	asserter.True(short.IsSubtypeOf(types["Name"]))
+	asserter.False(types["Name"].IsSubtypeOf(short))
+
+	// user-defined facets
+	euros := types["Euros"]
+	asserter.Equal("number", euros.Base)
+	asserter.Contains(euros.FacetDeclarations, "currency")
+	asserter.Equal("EUR", euros.Facets["currency"])
+
+	// properties are inherited from all parents
+	person := types["Person"]
+	asserter.Equal(KindObject, person.Kind)
+	asserter.Len(person.Parents, 2)
+	asserter.Len(person.Properties, 5)
+	asserter.Equal(types["lib.Id"], person.Properties["id"].Type)
+	asserter.False(person.Properties["created"].Required)
+	asserter.Equal(short, person.Properties["name"].Type)
+	asserter.True(person.IsSubtypeOf(types["Entity"]))
+
+	// arrays and unions
+	friends := person.Properties["friends"].Type
+	asserter.Equal(KindArray, friends.Kind)
+	asserter.Equal(person, friends.Items)
+	pet := person.Properties["pet"].Type
+	asserter.Equal(KindUnion, pet.Kind)
+	asserter.Equal([]*ResolvedType{types["Cat"], types["Dog"]}, pet.Members)
+	asserter.True(types["Cat"].IsSubtypeOf(pet))
+	asserter.Equal(KindArray, types["People"].Kind)
+	asserter.Equal(person, types["People"].Items)
+}
+
+func TestInvalidTypeHierarchies(t *testing.T) {
+	asserter := assert.New(t)
+
+	diagnostics, err := ParseFileAll("./testdata/types/invalid_hierarchy.raml", new(APIDefinition))
+	asserter.Error(err)
+	messages := map[string][]string{}
+	for _, d := range diagnostics {
+		messages[d.Code] = append(messages[d.Code], d.Message)
+	}
+
+	asserter.Equal([]string{"circular inheritance: Chicken -> Egg -> Chicken"}, messages[CodeCircularType])
+	asserter.Equal([]string{
+		"property name of Account has to be of a subtype of string, as inherited from Named",
+		"facet currency has been given the value EUR by Euros, it can't be changed",
+		"property name is inherited from Named and Numbered with different types",
+		"Tagged can only inherit from several object types, not from string",
+	}, messages[CodeInvalidInheritance])
+}
@@ -0,0 +1,624 @@
+package raml
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// This file implements the validation of instances, such as payloads
+// decoded from JSON or YAML, against the types of an API.
+// Instances are made of the values encoding/json and yaml decode into an
+// interface{}: nil, booleans, numbers, strings, []interface{} and maps.
+// Files are given as []byte.
+
+// Violation is a constraint of a type that an instance doesn't satisfy.
+type Violation struct {
+	// JSON pointer to the value violating the constraint, e.g. `/friends/0/name`,
+	// empty for the instance itself.
+	Path string
+
+	// The facet which is violated, e.g. `minLength`, `type` when the value
+	// isn't of the expected type.
+	Facet string
+
+	// Human-friendly description of the violation.
+	Message string
+}
+
+func (v Violation) String() string {
+	if v.Path == "" {
+		return fmt.Sprintf("%s [%s]", v.Message, v.Facet)
+	}
+	return fmt.Sprintf("%s: %s [%s]", v.Path, v.Message, v.Facet)
+}
+
+// ValidateInstance validates an instance against a type of an API, given
+// by a type expression, e.g. `Person`, `lib.Person[]` or `string`.
+// It returns the constraints of the type that the instance violates, none
+// if it's valid.
+// The API is left untouched, instances can be validated concurrently.
+func ValidateInstance(api *APIDefinition, typeRef string, value interface{}) []Violation {
+	if _, err := ParseTypeExpression(typeRef); err != nil {
+		return []Violation{{Facet: "type", Message: fmt.Sprintf("invalid type expression %q: %v", typeRef, err)}}
+	}
+	r, err := api.instanceResolver()
+	var t *ResolvedType
+	if err == nil {
+		t, err = r.resolve(typeRef, "")
+	}
+	if err != nil {
+		return []Violation{{Facet: "type", Message: err.Error()}}
+	}
+	v := instanceValidator{types: r.types}
+	v.validate(t, value, "")
+	return v.violations
+}
+
+// resolveType resolves the type declared by the value of a type facet
+// against the types of the API, as declared by the declaration qualified as scope
+func (d *APIDefinition) resolveType(tip interface{}, scope string) (*ResolvedType, error) {
+	r, err := d.instanceResolver()
+	if err != nil {
+		return nil, err
+	}
+	return r.resolve(tip, scope)
+}
+
+// instanceResolver returns a resolver of the types instances are validated against.
+// The resolved types of the API are copied rather than shared, resolved anew if the
+// parse didn't resolve them: ResolvedTypes is only read, for instances to be validated
+// concurrently.
+func (d *APIDefinition) instanceResolver() (*typeResolver, error) {
+	p := newParser()
+	r := newTypeResolver(p, d)
+	if d.ResolvedTypes == nil {
+		if err := r.resolveAll(); err != nil {
+			return nil, resolutionError(err)
+		}
+		return r, nil
+	}
+	for name, t := range d.ResolvedTypes {
+		r.types[name] = t
+	}
+	return r, nil
+}
+
+// resolve resolves the type declared by the value of a type facet,
+// as declared by the declaration qualified as scope
+func (r *typeResolver) resolve(tip interface{}, scope string) (*ResolvedType, error) {
+	t, err := r.typeOf(tip, &ResolvedType{scope: scope})
+	if err == nil {
+		err = r.complete(t)
+	}
+	if err != nil {
+		return nil, resolutionError(err)
+	}
+	return t, nil
+}
+
+// resolutionError returns the error a type can't be resolved with: the message of the
+// diagnostic reporting it, rather than the whole error of the parser resolving it
+func resolutionError(err error) error {
+	if e, ok := err.(*Error); ok && len(e.Diagnostics) > 0 {
+		return errors.New(e.Diagnostics[0].Message)
+	}
+	return err
+}
+
+// instanceValidator validates instances, recording the violations
+type instanceValidator struct {
+	types      map[string]*ResolvedType // the resolved types of the API, by qualified name
+	violations []Violation
+}
+
+func (v *instanceValidator) violate(path, facet, format string, args ...interface{}) {
+	v.violations = append(v.violations, Violation{Path: path, Facet: facet, Message: fmt.Sprintf(format, args...)})
+}
+
+// validate validates the value found at path against a type
+func (v *instanceValidator) validate(t *ResolvedType, value interface{}, path string) {
+	value = normalizeInstance(value)
+	switch t.Kind {
+	case KindAny:
+		return
+	case KindUnion:
+		v.validateUnion(t, value, path)
+		return
+	}
+	if value == nil && t.Base != "nil" {
+		v.violate(path, "type", "expected %s, got nil", t.Base)
+		return
+	}
+
+	switch t.Kind {
+	case KindScalar:
+		if v.validateScalar(t, value, path) {
+			v.validateEnum(t, value, path)
+		}
+	case KindObject:
+		v.validateObject(t, value, path)
+	case KindArray:
+		v.validateArray(t, value, path)
+	}
+}
+
+// validateUnion validates a value against the first type of a union it's an instance of
+func (v *instanceValidator) validateUnion(t *ResolvedType, value interface{}, path string) {
+	members := t.Members
+	if m, ok := discriminated(t, value); ok {
+		members = []*ResolvedType{m}
+	}
+	var violations []Violation
+	for _, m := range members {
+		member := instanceValidator{types: v.types}
+		member.validate(m, value, path)
+		if len(member.violations) == 0 {
+			return
+		}
+		if violations == nil || len(member.violations) < len(violations) {
+			violations = member.violations
+		}
+	}
+	if len(members) == 1 {
+		v.violations = append(v.violations, violations...)
+		return
+	}
+	names := make([]string, len(t.Members))
+	for i, m := range t.Members {
+		names[i] = m.displayName()
+	}
+	v.violate(path, "type", "expected one of %s", strings.Join(names, ", "))
+}
+
+// discriminated returns the member of a union of object types an object
+// is an instance of, as told by the value of its discriminator
+func discriminated(t *ResolvedType, value interface{}) (*ResolvedType, bool) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	for _, m := range t.Members {
+		discriminator, ok := m.Facets["discriminator"].(string)
+		if !ok {
+			continue
+		}
+		if equalInstances(object[discriminator], discriminatorValue(m)) {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// discriminatorValue returns the value of the discriminator identifying a type, its name by default
+func discriminatorValue(t *ResolvedType) interface{} {
+	if value, ok := t.declaration["discriminatorValue"]; ok {
+		return value
+	}
+	name := t.Name
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// dateTimeLayouts are the layouts of the date and time types
+var dateTimeLayouts = map[string]string{
+	"date-only":     "2006-01-02",
+	"time-only":     "15:04:05",
+	"datetime-only": "2006-01-02T15:04:05",
+	"datetime":      time.RFC3339,
+}
+
+// integerFormats are the ranges of the integer formats of numbers
+var integerFormats = map[string][2]float64{
+	"int8":  {math.MinInt8, math.MaxInt8},
+	"int16": {math.MinInt16, math.MaxInt16},
+	"int32": {math.MinInt32, math.MaxInt32},
+	"int64": {math.MinInt64, math.MaxInt64},
+	"int":   {math.MinInt32, math.MaxInt32},
+	"long":  {math.MinInt64, math.MaxInt64},
+}
+
+// validateScalar validates a value against a scalar type,
+// returning false if it's not even of the type
+func (v *instanceValidator) validateScalar(t *ResolvedType, value interface{}, path string) bool {
+	switch t.Base {
+	case "nil":
+		if value != nil {
+			v.violate(path, "type", "expected nil, got %s", instanceKind(value))
+			return false
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			v.violate(path, "type", "expected string, got %s", instanceKind(value))
+			return false
+		}
+		v.validateLength(t, utf8.RuneCountInString(s), "characters", path)
+		if pattern, ok := t.Facets["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err == nil && !re.MatchString(s) {
+				v.violate(path, "pattern", "%q doesn't match the pattern %s", s, pattern)
+			}
+		}
+	case "number", "integer":
+		n, ok := toFloat(value)
+		if !ok {
+			v.violate(path, "type", "expected %s, got %s", t.Base, instanceKind(value))
+			return false
+		}
+		format, _ := t.Facets["format"].(string)
+		_, integral := integerFormats[format]
+		if (t.Base == "integer" || integral) && n != math.Trunc(n) {
+			v.violate(path, "type", "expected integer, got %v", value)
+			return false
+		}
+		v.validateNumber(t, n, format, path)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.violate(path, "type", "expected boolean, got %s", instanceKind(value))
+			return false
+		}
+	case "date-only", "time-only", "datetime-only", "datetime":
+		return v.validateDateTime(t, value, path)
+	case "file":
+		return v.validateFile(t, value, path)
+	}
+	return true
+}
+
+// validateLength validates the length of a string or file
+func (v *instanceValidator) validateLength(t *ResolvedType, length int, unit, path string) {
+	if min, ok := toFloat(t.Facets["minLength"]); ok && float64(length) < min {
+		v.violate(path, "minLength", "expected at least %v %s, got %d", min, unit, length)
+	}
+	if max, ok := toFloat(t.Facets["maxLength"]); ok && float64(length) > max {
+		v.violate(path, "maxLength", "expected at most %v %s, got %d", max, unit, length)
+	}
+}
+
+// validateNumber validates the facets of a number
+func (v *instanceValidator) validateNumber(t *ResolvedType, n float64, format, path string) {
+	if min, ok := toFloat(t.Facets["minimum"]); ok && n < min {
+		v.violate(path, "minimum", "expected at least %v, got %v", min, n)
+	}
+	if max, ok := toFloat(t.Facets["maximum"]); ok && n > max {
+		v.violate(path, "maximum", "expected at most %v, got %v", max, n)
+	}
+	if m, ok := toFloat(t.Facets["multipleOf"]); ok && m != 0 {
+		if q := n / m; math.Abs(q-math.Round(q)) > 1e-9 {
+			v.violate(path, "multipleOf", "expected a multiple of %v, got %v", m, n)
+		}
+	}
+	if bounds, ok := integerFormats[format]; ok && (n < bounds[0] || n > bounds[1]) {
+		v.violate(path, "format", "%v is out of the range of %s", n, format)
+	}
+}
+
+// validateDateTime validates a date or time, given as a string
+func (v *instanceValidator) validateDateTime(t *ResolvedType, value interface{}, path string) bool {
+	if _, ok := value.(time.Time); ok {
+		return true
+	}
+	s, ok := value.(string)
+	if !ok {
+		v.violate(path, "type", "expected %s, got %s", t.Base, instanceKind(value))
+		return false
+	}
+	layout, name := dateTimeLayouts[t.Base], t.Base
+	if format, _ := t.Facets["format"].(string); t.Base == "datetime" && strings.EqualFold(format, "rfc2616") {
+		layout, name = http.TimeFormat, "RFC 2616 datetime"
+	}
+	if _, err := time.Parse(layout, s); err != nil {
+		v.violate(path, "format", "%q is not a valid %s", s, name)
+		return false
+	}
+	return true
+}
+
+// validateFile validates the size and media type of a file, given as its contents
+func (v *instanceValidator) validateFile(t *ResolvedType, value interface{}, path string) bool {
+	var contents []byte
+	switch f := value.(type) {
+	case []byte:
+		contents = f
+	case string:
+		contents = []byte(f)
+	default:
+		v.violate(path, "type", "expected file, got %s", instanceKind(value))
+		return false
+	}
+	v.validateLength(t, len(contents), "bytes", path)
+
+	fileTypes := toStrings(t.Facets["fileTypes"])
+	if len(fileTypes) == 0 {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(contents))
+	for _, fileType := range fileTypes {
+		if matchMediaType(fileType, mediaType) {
+			return true
+		}
+	}
+	v.violate(path, "fileTypes", "file of type %s is not one of %s", mediaType, strings.Join(fileTypes, ", "))
+	return true
+}
+
+// matchMediaType checks whether a media type matches a media range, e.g. `image/*`
+func matchMediaType(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || strings.EqualFold(mediaRange, mediaType) {
+		return true
+	}
+	if strings.HasSuffix(mediaRange, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*"))
+	}
+	return false
+}
+
+// validateEnum validates that a scalar is one of the values of its enumeration
+func (v *instanceValidator) validateEnum(t *ResolvedType, value interface{}, path string) {
+	enum, ok := t.Facets["enum"]
+	if !ok {
+		return
+	}
+	values, ok := enum.([]interface{})
+	if !ok {
+		values = []interface{}{enum}
+	}
+	for _, e := range values {
+		if equalInstances(e, value) {
+			return
+		}
+	}
+	v.violate(path, "enum", "expected one of %v, got %v", values, value)
+}
+
+// validateObject validates an object and its properties
+func (v *instanceValidator) validateObject(t *ResolvedType, value interface{}, path string) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		v.violate(path, "type", "expected object, got %s", instanceKind(value))
+		return
+	}
+	if sub, ok := v.discriminatedSubtype(t, object, path); ok && sub != t {
+		if sub != nil {
+			v.validate(sub, object, path)
+		}
+		return
+	}
+
+	if min, ok := toFloat(t.Facets["minProperties"]); ok && float64(len(object)) < min {
+		v.violate(path, "minProperties", "expected at least %v properties, got %d", min, len(object))
+	}
+	if max, ok := toFloat(t.Facets["maxProperties"]); ok && float64(len(object)) > max {
+		v.violate(path, "maxProperties", "expected at most %v properties, got %d", max, len(object))
+	}
+
+	var patterns []*ResolvedProperty
+	for _, name := range sortedKeys(t.Properties) {
+		p := t.Properties[name]
+		if isPatternProperty(name) {
+			patterns = append(patterns, p)
+			continue
+		}
+		propValue, ok := object[name]
+		if !ok {
+			if p.Required {
+				v.violate(path+"/"+escapePointer(name), "required", "missing required property %s", name)
+			}
+			continue
+		}
+		v.validate(p.Type, propValue, path+"/"+escapePointer(name))
+	}
+
+	additional, _ := t.Facets["additionalProperties"].(bool)
+	if _, ok := t.Facets["additionalProperties"]; !ok {
+		additional = true
+	}
+	for _, name := range sortedKeys(object) {
+		if _, ok := t.Properties[name]; ok {
+			continue
+		}
+		matched := false
+		for _, p := range patterns {
+			if re, err := regexp.Compile(p.Name[1 : len(p.Name)-1]); err == nil && re.MatchString(name) {
+				matched = true
+				v.validate(p.Type, object[name], path+"/"+escapePointer(name))
+				break
+			}
+		}
+		if !matched && !additional {
+			v.violate(path+"/"+escapePointer(name), "additionalProperties", "property %s is not declared", name)
+		}
+	}
+}
+
+// discriminatedSubtype returns the type an object is an instance of when its type has a discriminator:
+// the type itself or one of its subtypes, nil if the discriminator has no valid value.
+// It returns false if the type has no discriminator.
+func (v *instanceValidator) discriminatedSubtype(t *ResolvedType, object map[string]interface{},
+	path string) (*ResolvedType, bool) {
+	discriminator, ok := t.Facets["discriminator"].(string)
+	if !ok {
+		return nil, false
+	}
+	value := object[discriminator]
+	candidates := []*ResolvedType{t}
+	for _, name := range sortedKeys(v.types) {
+		if sub := v.types[name]; sub.inherits(t) {
+			candidates = append(candidates, sub)
+		}
+	}
+	var expected []string
+	for _, c := range candidates {
+		if equalInstances(value, discriminatorValue(c)) {
+			return c, true
+		}
+		expected = append(expected, fmt.Sprint(discriminatorValue(c)))
+	}
+	v.violate(path+"/"+escapePointer(discriminator), "discriminator", "expected one of %s, got %v",
+		strings.Join(expected, ", "), value)
+	return nil, true
+}
+
+// validateArray validates an array and its items
+func (v *instanceValidator) validateArray(t *ResolvedType, value interface{}, path string) {
+	items, ok := value.([]interface{})
+	if !ok {
+		v.violate(path, "type", "expected array, got %s", instanceKind(value))
+		return
+	}
+	if min, ok := toFloat(t.Facets["minItems"]); ok && float64(len(items)) < min {
+		v.violate(path, "minItems", "expected at least %v items, got %d", min, len(items))
+	}
+	if max, ok := toFloat(t.Facets["maxItems"]); ok && float64(len(items)) > max {
+		v.violate(path, "maxItems", "expected at most %v items, got %d", max, len(items))
+	}
+	if unique, _ := t.Facets["uniqueItems"].(bool); unique {
+	items:
+		for i := range items {
+			for j := 0; j < i; j++ {
+				if equalInstances(items[i], items[j]) {
+					v.violate(fmt.Sprintf("%s/%d", path, i), "uniqueItems", "item %d is equal to item %d", i, j)
+					continue items
+				}
+			}
+		}
+	}
+	if t.Items == nil {
+		return
+	}
+	for i, item := range items {
+		v.validate(t.Items, item, fmt.Sprintf("%s/%d", path, i))
+	}
+}
+
+// normalizeInstance converts the maps decoded by other decoders than
+// encoding/json and yaml.v3, and the slices of any type, to the types of instances
+func normalizeInstance(value interface{}) interface{} {
+	switch val := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[fmt.Sprint(k)] = item
+		}
+		return m
+	case []interface{}, []byte, map[string]interface{}:
+		return value
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice {
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+		return items
+	}
+	return value
+}
+
+// toFloat converts a number to a float64
+func toFloat(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	}
+	return 0, false
+}
+
+// toStrings converts a sequence of strings, or a single one, to a slice of strings
+func toStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		var s []string
+		for _, item := range v {
+			s = append(s, fmt.Sprint(item))
+		}
+		return s
+	}
+	return nil
+}
+
+// equalInstances compares two instances, numbers by value
+func equalInstances(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	a, b = normalizeInstance(a), normalizeInstance(b)
+	switch va := a.(type) {
+	case []interface{}:
+		vb, ok := b.([]interface{})
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if !equalInstances(va[i], vb[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		vb, ok := b.(map[string]interface{})
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for k := range va {
+			if !equalInstances(va[k], vb[k]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// instanceKind describes the kind of an instance for messages
+func instanceKind(value interface{}) string {
+	switch normalizeInstance(value).(type) {
+	case nil:
+		return "nil"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	if _, ok := toFloat(value); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// escapePointer escapes a property name for JSON pointers
+func escapePointer(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
@@ -0,0 +1,131 @@
+package raml
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatingInstances(t *testing.T) {
+	asserter := assert.New(t)
+
+	apiDefinition := new(APIDefinition)
+	asserter.NoError(ParseFile("./testdata/types/validation.raml", apiDefinition))
+
+	violations := func(typeRef string, value interface{}) []string {
+		var s []string
+		for _, v := range ValidateInstance(apiDefinition, typeRef, value) {
+			s = append(s, v.Path+" "+v.Facet)
+		}
+		return s
+	}
+
+	// scalars
+	asserter.Empty(violations("Code", "EUR"))
+	asserter.Equal([]string{" pattern"}, violations("Code", "euro"))
+	asserter.Equal([]string{" type"}, violations("Code", 12))
+	asserter.Equal([]string{" minLength"}, violations("Name", "é"))
+	asserter.Equal([]string{" maxLength"}, violations("Name", "Bartholomew"))
+	asserter.Empty(violations("Status", "active"))
+	asserter.Equal([]string{" enum"}, violations("Status", "dead"))
+	asserter.Empty(violations("Price", 12.5))
+	asserter.Equal([]string{" minimum", " multipleOf"}, violations("Price", -0.3))
+	asserter.Equal([]string{" maximum"}, violations("Price", 1001))
+	asserter.Empty(violations("Small", 127))
+	asserter.Equal([]string{" format"}, violations("Small", 128))
+	asserter.Equal([]string{" type"}, violations("Small", 1.5))
+	asserter.Empty(violations("boolean", true))
+	asserter.Equal([]string{" type"}, violations("string", nil))
+
+	// dates and times
+	asserter.Empty(violations("Birthday", "2015-05-23"))
+	asserter.Equal([]string{" format"}, violations("Birthday", "23/05/2015"))
+	asserter.Empty(violations("Opening", "12:30:00"))
+	asserter.Equal([]string{" format"}, violations("Opening", "12h30"))
+	asserter.Empty(violations("datetime", "2016-02-28T16:41:41.090Z"))
+	asserter.Empty(violations("Modified", "Sun, 28 Feb 2016 16:41:41 GMT"))
+	asserter.Equal([]string{" format"}, violations("Modified", "2016-02-28T16:41:41.090Z"))
+
+	// files
+	png := []byte("\x89PNG\x0D\x0A\x1A\x0A")
+	asserter.Empty(violations("Avatar", png))
+	asserter.Equal([]string{" fileTypes"}, violations("Avatar", []byte("plain text")))
+	asserter.Equal([]string{" maxLength"}, violations("Avatar", append(png, make([]byte, 1024)...)))
+
+	// arrays
+	asserter.Empty(violations("Tags", []interface{}{"a", "b"}))
+	asserter.Empty(violations("Tags", []string{"a", "b"}))
+	asserter.Equal([]string{" minItems"}, violations("Tags", []interface{}{}))
+	asserter.Equal([]string{" maxItems"}, violations("Tags", []interface{}{"a", "b", "c", "d"}))
+	asserter.Equal([]string{"/2 uniqueItems"}, violations("Tags", []interface{}{"a", "b", "a"}))
+	asserter.Equal([]string{"/1 type"}, violations("Tags", []interface{}{"a", 2}))
+	asserter.Equal([]string{"/1 pattern"}, violations("Code[]", []interface{}{"EUR", "usd"}))
+
+	// objects
+	asserter.Empty(violations("Product", map[string]interface{}{
+		"code": "EUR", "name": "Euro", "tags": []interface{}{"money"}, "note1": "fiat",
+	}))
+	asserter.Equal([]string{"/name required"}, violations("Product", map[string]interface{}{
+		"code": "EUR", "price": 1,
+	}))
+	asserter.Equal([]string{"/code pattern", "/tags/0 type", "/note~1x additionalProperties"},
+		violations("Product", map[string]interface{}{
+			"code": "euro", "name": "Euro", "tags": []interface{}{1}, "note/x": "",
+		}))
+	asserter.Equal([]string{" minProperties", "/name required"}, violations("Product", map[string]interface{}{
+		"code": "EUR",
+	}))
+	asserter.Equal([]string{" maxProperties"}, violations("Product", map[string]interface{}{
+		"code": "EUR", "name": "Euro", "note1": "", "note2": "", "note3": "",
+	}))
+	asserter.Equal([]string{"/note1 type"}, violations("Product", map[interface{}]interface{}{
+		"code": "EUR", "name": "Euro", "note1": 1,
+	}))
+
+	// discriminators and unions
+	asserter.Empty(violations("Animal", map[string]interface{}{"kind": "Cat", "name": "Tom", "lives": 9}))
+	asserter.Equal([]string{"/lives required"}, violations("Animal", map[string]interface{}{
+		"kind": "Cat", "name": "Tom",
+	}))
+	asserter.Equal([]string{"/kind discriminator"}, violations("Animal", map[string]interface{}{
+		"kind": "Dog", "name": "Rex",
+	}))
+	asserter.Empty(violations("Pet", map[string]interface{}{"kind": "doggy", "name": "Rex", "good": true}))
+	asserter.Equal([]string{"/good type"}, violations("Pet", map[string]interface{}{
+		"kind": "doggy", "name": "Rex", "good": "very",
+	}))
+	asserter.Empty(violations("Pet", nil))
+	asserter.Equal([]string{" type"}, violations("Pet", "Rex"))
+	asserter.Empty(violations("string | integer", 1))
+
+	// invalid references
+	asserter.Equal([]string{" type"}, violations("Unknown", "value"))
+	asserter.Equal([]string{" type"}, violations("Code[", "value"))
+}
+
+func TestValidatingInstancesConcurrently(t *testing.T) {
+	asserter := assert.New(t)
+
+	apiDefinition := new(APIDefinition)
+	asserter.NoError(ParseFile("./testdata/types/validation.raml", apiDefinition))
+	// as for an API built by hand, the types are resolved by each validation
+	apiDefinition.ResolvedTypes = nil
+
+	cat := map[string]interface{}{"kind": "Cat", "name": "Tom", "lives": 9}
+	results := make([][]Violation, 8)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = ValidateInstance(apiDefinition, "Animal", cat)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, violations := range results {
+		asserter.Empty(violations)
+	}
+	asserter.Nil(apiDefinition.ResolvedTypes)
+}